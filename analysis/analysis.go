@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Tokenizer splits the keys and the queries into the tokens they are indexed and searched by.
+// The nodes of a ring have to tokenize alike, as the index node of a token tokenizes the keys it stores
+// while the node serving a request tokenizes the query.
+type Tokenizer interface {
+	// Name identifies the tokenizer and its settings, so that the nodes can tell whether they tokenize alike.
+	Name() string
+	// Tokenize returns the normalized tokens of the text in order, including the repeated ones.
+	Tokenize(text string) []string
+}
+
+// Whitespace lowercases the text and splits it on spaces, the way the keys were tokenized before the analyzers.
+type Whitespace struct{}
+
+func (Whitespace) Name() string {
+	return "whitespace"
+}
+
+func (Whitespace) Tokenize(text string) []string {
+	return strings.Fields(strings.ToLower(text))
+}
+
+// Standard splits the text on anything but letters and digits, lowercases the tokens
+// and drops the stop words, optionally stemming the rest.
+type Standard struct {
+	StopWords map[string]bool
+	Stem      bool
+}
+
+// NewStandard creates a standard analyzer dropping the English stop words.
+func NewStandard(stem bool) Standard {
+	return Standard{StopWords: EnglishStopWords, Stem: stem}
+}
+
+func (s Standard) Name() string {
+	if s.Stem {
+		return "standard+stem"
+	}
+	return "standard"
+}
+
+func (s Standard) Tokenize(text string) []string {
+	// Apostrophes join the words, as in "don't"
+	text = strings.NewReplacer("'", "", "’", "").Replace(text)
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.Is(unicode.Mn, r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		token := strings.ToLower(field)
+		if s.StopWords[token] {
+			continue
+		}
+		if s.Stem {
+			token = stem(token)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens
+}
+
+// Parse returns the tokenizer of the name, as given by Tokenizer.Name.
+func Parse(name string) (Tokenizer, error) {
+	switch name {
+	case "whitespace":
+		return Whitespace{}, nil
+	case "standard":
+		return NewStandard(false), nil
+	case "standard+stem":
+		return NewStandard(true), nil
+	default:
+		return nil, fmt.Errorf("unknown tokenizer %q", name)
+	}
+}
+
+// Distinct returns the tokens without the repeated ones, in the order they first appear.
+// A key is indexed once under each of its distinct tokens.
+func Distinct(tokens []string) []string {
+	distinct := make([]string, 0, len(tokens))
+	seen := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		if !seen[token] {
+			seen[token] = true
+			distinct = append(distinct, token)
+		}
+	}
+
+	return distinct
+}
+
+// EnglishStopWords are the words too common to be worth indexing.
+var EnglishStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true,
+	"by": true, "for": true, "if": true, "in": true, "into": true, "is": true, "it": true, "no": true,
+	"not": true, "of": true, "on": true, "or": true, "such": true, "that": true, "the": true, "their": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true, "to": true, "was": true,
+	"will": true, "with": true,
+}
@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func Test_Tokenize(t *testing.T) {
+	cases := []struct {
+		tokenizer Tokenizer
+		text      string
+		expected  []string
+	}{
+		{tokenizer: Whitespace{}, text: "Lord of  the\tRings", expected: []string{"lord", "of", "the", "rings"}},
+		{tokenizer: NewStandard(false), text: "The Lord of the Rings: The Two Towers", expected: []string{"lord", "rings", "two", "towers"}},
+		{tokenizer: NewStandard(false), text: "rock-n-roll,\tdon't  STOP", expected: []string{"rock", "n", "roll", "dont", "stop"}},
+		{tokenizer: NewStandard(false), text: "Ünïcödé Straße 東京 café", expected: []string{"ünïcödé", "straße", "東京", "café"}},
+		{tokenizer: NewStandard(false), text: "the of and", expected: []string{}},
+		{tokenizer: NewStandard(true), text: "running dogs flies boxes walked glass sing", expected: []string{"run", "dog", "fly", "box", "walk", "glass", "sing"}},
+	}
+	for _, c := range cases {
+		tokens := c.tokenizer.Tokenize(c.text)
+		if !slices.Equal(tokens, c.expected) {
+			t.Fatalf("[%s/%s] expected %v, got %v", c.tokenizer.Name(), c.text, c.expected, tokens)
+		}
+	}
+
+	distinct := Distinct([]string{"lord", "lord", "war", "lord"})
+	if !slices.Equal(distinct, []string{"lord", "war"}) {
+		t.Fatalf("expected [lord war], got %v", distinct)
+	}
+
+	for _, tokenizer := range []Tokenizer{Whitespace{}, NewStandard(false), NewStandard(true)} {
+		parsed, err := Parse(tokenizer.Name())
+		if err != nil || parsed.Name() != tokenizer.Name() {
+			t.Fatalf("[%s] parse failed: %v", tokenizer.Name(), err)
+		}
+	}
+}
+
+func Test_Fuzzy(t *testing.T) {
+	deletions := Deletions("ring")
+	if !slices.Equal(deletions, []string{"ing", "rng", "rig", "rin"}) {
+		t.Fatalf("expected the deletions of ring, got %v", deletions)
+	}
+	if deletions := Deletions("lood"); !slices.Equal(deletions, []string{"ood", "lod", "loo"}) {
+		t.Fatalf("expected the distinct deletions of lood, got %v", deletions)
+	}
+
+	cases := []struct {
+		a, b   string
+		within bool
+	}{
+		{a: "lord", b: "lord", within: true},
+		{a: "lord", b: "lrd", within: true},
+		{a: "lord", b: "lorde", within: true},
+		{a: "lord", b: "lard", within: true},
+		{a: "lord", b: "lrod", within: true},
+		{a: "lord", b: "olrd", within: true},
+		{a: "lord", b: "ldro", within: false},
+		{a: "lord", b: "lo", within: false},
+		{a: "café", b: "cafe", within: true},
+		{a: "rings", b: "wings", within: true},
+		{a: "rings", b: "wigs", within: false},
+	}
+	for _, c := range cases {
+		if WithinDistance(c.a, c.b, 1) != c.within {
+			t.Fatalf("[%s/%s] expected within %v", c.a, c.b, c.within)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package analysis
+
+// MinFuzzyLength is the length of the shortest token matched fuzzily. Shorter tokens are within a single edit
+// of too many others, so they only match exactly.
+const MinFuzzyLength = 4
+
+// Deletions returns the distinct variants of the token missing one of its letters. Two tokens within an edit
+// of each other share the token itself or one of its deletion variants, which makes the variants an index
+// for the fuzzy lookups.
+func Deletions(token string) []string {
+	runes := []rune(token)
+	variants := make([]string, 0, len(runes))
+	for i := range runes {
+		if i > 0 && runes[i] == runes[i-1] {
+			// Deleting either of the repeated letters yields the same variant
+			continue
+		}
+		variants = append(variants, string(runes[:i])+string(runes[i+1:]))
+	}
+
+	return variants
+}
+
+// WithinDistance reports whether the tokens are within the given number of insertions, deletions, substitutions
+// and transpositions of adjacent letters of each other.
+func WithinDistance(a string, b string, distance int) bool {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > distance {
+		return false
+	}
+
+	// Optimal string alignment distance, keeping the last two rows
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+
+		if rowMin > distance {
+			return false
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(rb)] <= distance
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,43 @@
+package analysis
+
+import (
+	"strings"
+)
+
+// stem strips the English plural and verb suffixes off the token, a light stemmer
+// in the spirit of the S-stemmer extended to "-ed" and "-ing". Tokens of other languages pass through.
+func stem(token string) string {
+	if len(token) <= 3 {
+		return token
+	}
+
+	switch {
+	case strings.HasSuffix(token, "ies") && !strings.HasSuffix(token, "eies") && !strings.HasSuffix(token, "aies"):
+		return token[:len(token)-3] + "y"
+	case strings.HasSuffix(token, "sses"), strings.HasSuffix(token, "xes"), strings.HasSuffix(token, "zes"),
+		strings.HasSuffix(token, "ches"), strings.HasSuffix(token, "shes"):
+		return token[:len(token)-2]
+	case strings.HasSuffix(token, "s") && !strings.HasSuffix(token, "ss") && !strings.HasSuffix(token, "us") && !strings.HasSuffix(token, "is"):
+		return token[:len(token)-1]
+	case strings.HasSuffix(token, "ing") && hasVowel(token[:len(token)-3]) && len(token) > 5:
+		return undouble(token[:len(token)-3])
+	case strings.HasSuffix(token, "ed") && hasVowel(token[:len(token)-2]) && len(token) > 4:
+		return undouble(token[:len(token)-2])
+	}
+
+	return token
+}
+
+func hasVowel(s string) bool {
+	return strings.ContainsAny(s, "aeiouy")
+}
+
+// undouble drops the doubled consonant left by stripping a suffix, as in "running" -> "run".
+func undouble(s string) string {
+	n := len(s)
+	if n >= 2 && s[n-1] == s[n-2] && !strings.ContainsRune("aeioulsz", rune(s[n-1])) {
+		return s[:n-1]
+	}
+
+	return s
+}
@@ -1,167 +1,843 @@
 package bucketmap
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/yousuf64/chord-kv/analysis"
 	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/hlc"
 	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/query"
+	"github.com/yousuf64/chord-kv/store"
 	"github.com/yousuf64/chord-kv/util"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
-type Item struct {
-	Index string
-	Key   string
-	Value string
-}
+// Item is an item stored in the bucket map.
+type Item = node.InsertItem
 
 type item struct {
-	Index  string   `json:"index"`
-	SecIdx []string `json:"secondary_indexes"`
-	Key    string   `json:"key"`
-	Value  string   `json:"value"`
+	id        uint64
+	Index     string        `json:"index"`
+	SecIdx    []string      `json:"secondary_indexes"`
+	Key       string        `json:"key"`
+	Value     string        `json:"value"`
+	Version   hlc.Timestamp `json:"version"`
+	ExpiresAt time.Time     `json:"expires_at"`
+}
+
+func (it item) toItem() Item {
+	return Item{Index: it.Index, Key: it.Key, Value: it.Value, Version: it.Version, ExpiresAt: it.ExpiresAt}
+}
+
+// expired reports whether the deadline of the item passed by the time.
+func (it item) expired(now time.Time) bool {
+	return !it.ExpiresAt.IsZero() && !now.Before(it.ExpiresAt)
+}
+
+// errStale tells that a write lost to the item or the tombstone held by the bucket, and got ignored.
+var errStale = errors.New("stale write")
+
+// supersedes reports whether the write of the item wins over the item held by last-writer-wins. The ties between
+// the versions resolve by the values, so that the replicas applying the writes in different orders agree on the winner.
+// The unversioned writes replace each other in the order they arrive.
+func supersedes(write Item, held item) bool {
+	if write.Version != held.Version {
+		return write.Version > held.Version
+	}
+	return write.Version.IsZero() || write.Value >= held.Value
 }
 
 type bucket struct {
-	lock          sync.RWMutex
-	items         []item
-	uniqueIndexes sync.Map
+	lock        sync.RWMutex
+	items       []item             // Sorted by id
+	ids         map[itemKey]uint64 // Index and key -> id of the item
+	index       invertedIndex
+	cardinality map[string]int            // Index -> Number of items
+	tombstones  map[itemKey]hlc.Timestamp // Deleted item -> Version of the deletion
+	nextId      uint64
+}
+
+// itemKey identifies an item by its index and its key, or the deleted item a tombstone stands for.
+type itemKey struct {
+	index string
+	key   string
+}
+
+func newBucket() *bucket {
+	return &bucket{
+		lock:        sync.RWMutex{},
+		items:       make([]item, 0),
+		ids:         map[itemKey]uint64{},
+		index:       invertedIndex{},
+		cardinality: map[string]int{},
+		tombstones:  map[itemKey]hlc.Timestamp{},
+	}
+}
+
+// find returns the position of the item with the id in the items.
+func (bkt *bucket) find(id uint64) (int, bool) {
+	i := sort.Search(len(bkt.items), func(i int) bool { return bkt.items[i].id >= id })
+	return i, i < len(bkt.items) && bkt.items[i].id == id
 }
 
 type BucketMap struct {
+	space   util.Space
 	buckets sync.Map // NodeId -> [ { Index: 'hello', Key: 'hello world', 'foo' }, { Index: 'hello', Key: 'hello world', 'foo' } ]
+	// tokenizer splits the keys into the secondary indexes. The queries arrive tokenized, joined by spaces.
+	tokenizer analysis.Tokenizer
+	// tombstoneTTL is how long after their versions the tombstones are kept around, dropped by Compact afterward.
+	tombstoneTTL time.Duration
+
+	// Persistence, only when opened with Open
+	dir     string
+	wal     *os.File
+	walLock sync.Mutex // Orders the mutations the same way in memory and in the write-ahead log
 }
 
-func NewBucketMap() *BucketMap {
+var _ store.Store = (*BucketMap)(nil)
+
+// Engine opens in-memory bucket maps, or bucket maps persisted under a subdirectory of Dir per node when set.
+// The keys are tokenized by Tokenizer, or by analysis.Whitespace when nil.
+// The tombstones are kept for TombstoneTTL, or for DefaultTombstoneTTL when zero.
+type Engine struct {
+	Dir          string
+	Tokenizer    analysis.Tokenizer
+	TombstoneTTL time.Duration
+}
+
+// DefaultTombstoneTTL is how long the tombstones of the deleted items are kept by default.
+const DefaultTombstoneTTL = time.Hour
+
+func (e Engine) Open(space util.Space, id util.ID, name string) (store.Store, error) {
+	tokenizer := e.Tokenizer
+	if tokenizer == nil {
+		tokenizer = analysis.Whitespace{}
+	}
+
+	if e.Dir == "" {
+		b := NewBucketMap(space)
+		b.tokenizer = tokenizer
+		b.setTombstoneTTL(e.TombstoneTTL)
+		return b, nil
+	}
+
+	b, err := Open(space, filepath.Join(e.Dir, id.String(), name), tokenizer)
+	if err != nil {
+		return nil, err
+	}
+
+	b.setTombstoneTTL(e.TombstoneTTL)
+	return b, nil
+}
+
+func (b *BucketMap) setTombstoneTTL(ttl time.Duration) {
+	if ttl > 0 {
+		b.tombstoneTTL = ttl
+	}
+}
+
+// NewBucketMap creates an in-memory BucketMap tokenizing the keys with analysis.Whitespace.
+func NewBucketMap(space util.Space) *BucketMap {
 	return &BucketMap{
-		buckets: sync.Map{},
+		space:        space,
+		buckets:      sync.Map{},
+		tokenizer:    analysis.Whitespace{},
+		tombstoneTTL: DefaultTombstoneTTL,
+	}
+}
+
+func (b *BucketMap) Add(bucketId util.ID, insertItem node.InsertItem) error {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
+	// Writes are serialized by walLock, so the item checked gets added once logged. Logging it beforehand keeps
+	// a failing append from dropping the expired item or the tombstone the item makes way for
+	err := b.addable(bucketId, insertItem)
+	if errors.Is(err, errStale) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = b.append(record{Op: opAdd, Bucket: bucketId.String(), Index: insertItem.Index, Key: insertItem.Key, Value: insertItem.Value, Version: uint64(insertItem.Version), ExpiresAt: util.UnixMilli(insertItem.ExpiresAt)})
+	if err != nil {
+		return err
+	}
+
+	return b.add(bucketId, insertItem)
+}
+
+// addable tells whether the item can be added, failing as add does otherwise.
+func (b *BucketMap) addable(bucketId util.ID, insertItem node.InsertItem) error {
+	val, ok := b.buckets.Load(bucketId)
+	if !ok {
+		return nil
+	}
+
+	bkt := val.(*bucket)
+	bkt.lock.RLock()
+	defer bkt.lock.RUnlock()
+
+	return bkt.addable(insertItem)
+}
+
+// addable tells whether the item can be added to the bucket, failing with errs.AlreadyExistsError when the item
+// is stored already and with errStale when a later version of the item was deleted. Expects the caller to hold
+// the lock of the bucket.
+func (bkt *bucket) addable(insertItem node.InsertItem) error {
+	if i := bkt.indexOf(insertItem.Index, insertItem.Key); i >= 0 && !bkt.items[i].expired(time.Now()) {
+		log.Println("already have item", insertItem.Key)
+		return errs.AlreadyExistsError
+	}
+
+	if deleted, ok := bkt.tombstones[itemKey{index: insertItem.Index, key: insertItem.Key}]; ok && insertItem.Version <= deleted {
+		return errStale
+	}
+
+	return nil
+}
+
+func (b *BucketMap) add(bucketId util.ID, insertItem node.InsertItem) error {
+	val, _ := b.buckets.LoadOrStore(bucketId, newBucket())
+
+	bkt := val.(*bucket)
+	bkt.lock.Lock()
+	defer bkt.lock.Unlock()
+
+	err := bkt.addable(insertItem)
+	if err != nil {
+		return err
+	}
+
+	// The expired item awaiting the reaper makes way for the new one
+	if i := bkt.indexOf(insertItem.Index, insertItem.Key); i >= 0 {
+		bkt.removeAt(i)
+	}
+	k := itemKey{index: insertItem.Index, key: insertItem.Key}
+	delete(bkt.tombstones, k)
+
+	secIdx := b.tokenizer.Tokenize(insertItem.Key)
+	it := item{
+		id:        bkt.nextId,
+		Index:     insertItem.Index,
+		SecIdx:    secIdx,
+		Key:       insertItem.Key,
+		Value:     insertItem.Value,
+		Version:   insertItem.Version,
+		ExpiresAt: insertItem.ExpiresAt,
+	}
+	bkt.nextId++
+	bkt.items = append(bkt.items, it)
+	bkt.ids[k] = it.id
+	bkt.index.add(it)
+	bkt.cardinality[it.Index]++
+
+	return nil
+}
+
+func (b *BucketMap) Put(bucketId util.ID, insertItem node.InsertItem) (bool, error) {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
+	existed, err := b.put(bucketId, insertItem, false)
+	if errors.Is(err, errStale) {
+		return existed, nil
+	}
+	if err != nil {
+		return existed, err
+	}
+
+	return existed, b.append(record{Op: opPut, Bucket: bucketId.String(), Index: insertItem.Index, Key: insertItem.Key, Value: insertItem.Value, Version: uint64(insertItem.Version), ExpiresAt: util.UnixMilli(insertItem.ExpiresAt)})
+}
+
+func (b *BucketMap) Replace(bucketId util.ID, insertItem node.InsertItem) (bool, error) {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
+	existed, err := b.put(bucketId, insertItem, true)
+	if errors.Is(err, errStale) {
+		return existed, nil
+	}
+	if err != nil || !existed {
+		return existed, err
+	}
+
+	return true, b.append(record{Op: opPut, Bucket: bucketId.String(), Index: insertItem.Index, Key: insertItem.Key, Value: insertItem.Value, Version: uint64(insertItem.Version), ExpiresAt: util.UnixMilli(insertItem.ExpiresAt)})
+}
+
+func (b *BucketMap) Swap(bucketId util.ID, insertItem node.InsertItem, expected hlc.Timestamp) (bool, error) {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
+	// Writes are serialized by walLock, so the item holds the version until it gets replaced
+	held, ok := b.version(bucketId, insertItem.Index, insertItem.Key)
+	if !ok {
+		return false, nil
+	}
+	if held != expected {
+		return true, errs.VersionMismatchError
+	}
+
+	_, err := b.put(bucketId, insertItem, true)
+	if errors.Is(err, errStale) {
+		return true, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	return true, b.append(record{Op: opPut, Bucket: bucketId.String(), Index: insertItem.Index, Key: insertItem.Key, Value: insertItem.Value, Version: uint64(insertItem.Version), ExpiresAt: util.UnixMilli(insertItem.ExpiresAt)})
+}
+
+// version returns the version of the item with the index and the key, if the bucket holds it.
+func (b *BucketMap) version(bucketId util.ID, index string, key string) (hlc.Timestamp, bool) {
+	it, ok := b.Get(bucketId, index, key)
+	return it.Version, ok
+}
+
+func (b *BucketMap) Get(bucketId util.ID, index string, key string) (Item, bool) {
+	val, ok := b.buckets.Load(bucketId)
+	if !ok {
+		return Item{}, false
+	}
+
+	bkt := val.(*bucket)
+	bkt.lock.RLock()
+	defer bkt.lock.RUnlock()
+
+	i := bkt.indexOf(index, key)
+	if i < 0 || bkt.items[i].expired(time.Now()) {
+		return Item{}, false
+	}
+
+	return bkt.items[i].toItem(), true
+}
+
+// put replaces the item in place, keeping its position in the insertion order, or adds the item
+// when missing unless existing is set.
+func (b *BucketMap) put(bucketId util.ID, insertItem node.InsertItem, existing bool) (bool, error) {
+	if val, ok := b.buckets.Load(bucketId); ok {
+		bkt := val.(*bucket)
+		bkt.lock.Lock()
+		if i := bkt.indexOf(insertItem.Index, insertItem.Key); i >= 0 {
+			defer bkt.lock.Unlock()
+			if existing && bkt.items[i].expired(time.Now()) {
+				return false, nil
+			}
+			if !supersedes(insertItem, bkt.items[i]) {
+				return true, errStale
+			}
+
+			bkt.items[i].Value = insertItem.Value
+			bkt.items[i].Version = insertItem.Version
+			bkt.items[i].ExpiresAt = insertItem.ExpiresAt
+			return true, nil
+		}
+		bkt.lock.Unlock()
 	}
+
+	if existing {
+		return false, nil
+	}
+
+	// Writes are serialized by walLock, so the item is still missing
+	return false, b.add(bucketId, insertItem)
 }
 
-func (b *BucketMap) Add(bucketId uint64, insertItem node.InsertItem) error {
-	val, _ := b.buckets.LoadOrStore(bucketId, &bucket{
-		lock:  sync.RWMutex{},
-		items: make([]item, 0),
+// Scan returns up to limit items and tombstones of the buckets within the range (lo, hi] without removing them,
+// resuming after the cursor. Leaves out the expired items.
+func (b *BucketMap) Scan(lo util.ID, hi util.ID, after store.Cursor, limit int) ([]Item, []node.DeleteItem, store.Cursor, bool) {
+	ids := make([]util.ID, 0)
+	b.buckets.Range(func(key, _ any) bool {
+		id := key.(util.ID)
+		if b.space.Between(id, lo, hi) && (!after.Started || bytes.Compare(id[:], after.Bucket[:]) >= 0) {
+			ids = append(ids, id)
+		}
+		return true
 	})
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i][:], ids[j][:]) < 0 })
+
+	items := make([]Item, 0)
+	deletes := make([]node.DeleteItem, 0)
+	full := func() bool { return limit > 0 && len(items)+len(deletes) >= limit }
+
+	now := time.Now()
+	cursor := after
+	for _, id := range ids {
+		if !cursor.Started || cursor.Bucket != id {
+			cursor = store.Cursor{Bucket: id, Started: true}
+		}
+
+		val, ok := b.buckets.Load(id)
+		if !ok {
+			continue
+		}
+
+		bkt := val.(*bucket)
+		bkt.lock.RLock()
+		if !cursor.Tombstones {
+			i, _ := bkt.find(cursor.Next)
+			for ; i < len(bkt.items) && !full(); i++ {
+				cursor.Next = bkt.items[i].id + 1
+				if !bkt.items[i].expired(now) {
+					items = append(items, bkt.items[i].toItem())
+				}
+			}
+			if i < len(bkt.items) {
+				bkt.lock.RUnlock()
+				return items, deletes, cursor, false
+			}
+			cursor.Tombstones = true
+		}
+
+		for _, ts := range bkt.sortedTombstones() {
+			if ts.index < cursor.Index || (ts.index == cursor.Index && ts.key <= cursor.Key) {
+				continue
+			}
+			if full() {
+				bkt.lock.RUnlock()
+				return items, deletes, cursor, false
+			}
+
+			deletes = append(deletes, node.DeleteItem{Index: ts.index, Key: ts.key, Version: bkt.tombstones[ts]})
+			cursor.Index, cursor.Key = ts.index, ts.key
+		}
+		bkt.lock.RUnlock()
+	}
+
+	return items, deletes, cursor, true
+}
+
+// sortedTombstones returns the keys of the tombstones of the bucket ordered by their indexes and keys.
+// Expects the caller to hold the lock of the bucket.
+func (bkt *bucket) sortedTombstones() []itemKey {
+	keys := make([]itemKey, 0, len(bkt.tombstones))
+	for ts := range bkt.tombstones {
+		keys = append(keys, ts)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].index != keys[j].index {
+			return keys[i].index < keys[j].index
+		}
+		return keys[i].key < keys[j].key
+	})
+
+	return keys
+}
+
+func (b *BucketMap) Count(lo util.ID, hi util.ID) int {
+	count := 0
+	b.buckets.Range(func(key, value any) bool {
+		if b.space.Between(key.(util.ID), lo, hi) {
+			bkt := value.(*bucket)
+			bkt.lock.RLock()
+			count += len(bkt.items) + len(bkt.tombstones)
+			bkt.lock.RUnlock()
+		}
+		return true
+	})
+
+	return count
+}
+
+// appendTombstones appends the tombstones of the bucket as the deletions of the items they stand for.
+// Expects the caller to hold the lock of the bucket.
+func (bkt *bucket) appendTombstones(deletes []node.DeleteItem) []node.DeleteItem {
+	for ts, version := range bkt.tombstones {
+		deletes = append(deletes, node.DeleteItem{Index: ts.index, Key: ts.key, Version: version})
+	}
+
+	return deletes
+}
+
+// Remove deletes the item with the index and the key from the bucket when it is still of the version.
+func (b *BucketMap) Remove(bucketId util.ID, index string, key string, version hlc.Timestamp) (bool, error) {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
+	if !b.remove(bucketId, index, key, version) {
+		return false, nil
+	}
+
+	return true, b.append(record{Op: opRemove, Bucket: bucketId.String(), Index: index, Key: key, Version: uint64(version)})
+}
+
+func (b *BucketMap) remove(bucketId util.ID, index string, key string, version hlc.Timestamp) bool {
+	val, ok := b.buckets.Load(bucketId)
+	if !ok {
+		return false
+	}
 
 	bkt := val.(*bucket)
 	bkt.lock.Lock()
 	defer bkt.lock.Unlock()
 
-	uqIdx := fmt.Sprintf("%s/%s", insertItem.Index, insertItem.Key)
-	_, exists := bkt.uniqueIndexes.LoadOrStore(uqIdx, struct{}{})
-	if exists {
-		log.Println("already have item", insertItem.Key)
-		return errs.AlreadyExistsError
+	i := bkt.indexOf(index, key)
+	if i < 0 || bkt.items[i].Version != version {
+		return false
+	}
+
+	bkt.removeAt(i)
+	return true
+}
+
+// indexOf returns the position of the item with the index and the key, -1 when missing.
+func (bkt *bucket) indexOf(index string, key string) int {
+	id, ok := bkt.ids[itemKey{index: index, key: key}]
+	if !ok {
+		return -1
+	}
+
+	i, _ := bkt.find(id)
+	return i
+}
+
+// removeAt removes the item at the position from the bucket. Expects the caller to hold the lock of the bucket.
+func (bkt *bucket) removeAt(i int) {
+	it := bkt.items[i]
+	bkt.items = append(bkt.items[:i], bkt.items[i+1:]...)
+	bkt.index.remove(it)
+	bkt.cardinality[it.Index]--
+	if bkt.cardinality[it.Index] == 0 {
+		delete(bkt.cardinality, it.Index)
+	}
+	delete(bkt.ids, itemKey{index: it.Index, key: it.Key})
+}
+
+// Delete removes the item with the index and the key from the bucket unless it is of a later version,
+// leaving a tombstone of the version in its place.
+func (b *BucketMap) Delete(bucketId util.ID, index string, key string, version hlc.Timestamp) (bool, error) {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
+	removed, err := b.delete(bucketId, index, key, version)
+	if errors.Is(err, errStale) {
+		return false, nil
+	}
+
+	return removed, b.append(record{Op: opDelete, Bucket: bucketId.String(), Index: index, Key: key, Version: uint64(version)})
+}
+
+func (b *BucketMap) delete(bucketId util.ID, index string, key string, version hlc.Timestamp) (bool, error) {
+	val, _ := b.buckets.LoadOrStore(bucketId, newBucket())
+	bkt := val.(*bucket)
+	bkt.lock.Lock()
+	defer bkt.lock.Unlock()
+
+	ts := itemKey{index: index, key: key}
+	if deleted, ok := bkt.tombstones[ts]; ok && deleted >= version {
+		return false, errStale
+	}
+
+	removed := false
+	if i := bkt.indexOf(index, key); i >= 0 {
+		if bkt.items[i].Version > version {
+			return false, errStale
+		}
+
+		// The expired item is gone already as far as the reads are concerned
+		removed = !bkt.items[i].expired(time.Now())
+		bkt.removeAt(i)
 	}
 
-	secIdx := strings.Split(insertItem.Key, " ")
-	bkt.items = append(bkt.items, item{
-		Index:  insertItem.Index,
-		SecIdx: secIdx,
-		Key:    insertItem.Key,
-		Value:  insertItem.Value,
+	bkt.tombstones[ts] = version
+	return removed, nil
+}
+
+// Forget drops the tombstone of the item with the index and the key from the bucket when it is still of the version.
+func (b *BucketMap) Forget(bucketId util.ID, index string, key string, version hlc.Timestamp) (bool, error) {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
+	if !b.forget(bucketId, index, key, version) {
+		return false, nil
+	}
+
+	return true, b.append(record{Op: opForget, Bucket: bucketId.String(), Index: index, Key: key, Version: uint64(version)})
+}
+
+func (b *BucketMap) forget(bucketId util.ID, index string, key string, version hlc.Timestamp) bool {
+	val, ok := b.buckets.Load(bucketId)
+	if !ok {
+		return false
+	}
+
+	bkt := val.(*bucket)
+	bkt.lock.Lock()
+	defer bkt.lock.Unlock()
+
+	ts := itemKey{index: index, key: key}
+	if deleted, ok := bkt.tombstones[ts]; !ok || deleted != version {
+		return false
+	}
+
+	delete(bkt.tombstones, ts)
+	return true
+}
+
+func (b *BucketMap) Tombstone(bucketId util.ID, index string, key string) (hlc.Timestamp, bool) {
+	val, ok := b.buckets.Load(bucketId)
+	if !ok {
+		return 0, false
+	}
+
+	bkt := val.(*bucket)
+	bkt.lock.RLock()
+	defer bkt.lock.RUnlock()
+
+	version, ok := bkt.tombstones[itemKey{index: index, key: key}]
+	return version, ok
+}
+
+// expireTombstones drops the tombstones whose versions are older than the time.
+func (b *BucketMap) expireTombstones(before time.Time) {
+	b.buckets.Range(func(_, value any) bool {
+		bkt := value.(*bucket)
+		bkt.lock.Lock()
+		for ts, version := range bkt.tombstones {
+			if version.WallTime().Before(before) {
+				delete(bkt.tombstones, ts)
+			}
+		}
+		bkt.lock.Unlock()
+		return true
 	})
+}
 
-	return nil
+// Expire evicts the items whose deadlines passed by the time, returning the number of the items evicted.
+// The evictions are not logged, since the items carry their deadlines into the log and expire again on replay.
+func (b *BucketMap) Expire(now time.Time) (int, error) {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
+	evicted := 0
+	b.buckets.Range(func(_, value any) bool {
+		bkt := value.(*bucket)
+		bkt.lock.Lock()
+		for i := len(bkt.items) - 1; i >= 0; i-- {
+			if bkt.items[i].expired(now) {
+				bkt.removeAt(i)
+				evicted++
+			}
+		}
+		bkt.lock.Unlock()
+		return true
+	})
+
+	return evicted, nil
 }
 
-func (b *BucketMap) GetAndDeleteLessThanEqual(lo uint64, hi uint64) []Item {
+// GetAndDeleteRange removes and returns the items and the tombstones of the buckets within the range (lo, hi].
+func (b *BucketMap) GetAndDeleteRange(lo util.ID, hi util.ID) ([]Item, []node.DeleteItem, error) {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
 	items := make([]Item, 0)
+	deletes := make([]node.DeleteItem, 0)
 
+	var err error
 	b.buckets.Range(func(key, value any) bool {
-		if !util.Between(key.(uint64), lo, hi) {
+		if b.space.Between(key.(util.ID), lo, hi) {
 			bkt := value.(*bucket)
+			bkt.lock.RLock()
 			for _, it := range bkt.items {
-				items = append(items, Item{
-					Index: it.Index,
-					Key:   it.Key,
-					Value: it.Value,
-				})
+				items = append(items, it.toItem())
 			}
+			deletes = bkt.appendTombstones(deletes)
+			bkt.lock.RUnlock()
 
-			b.buckets.Delete(key)
+			b.drop(key.(util.ID))
+			err = b.append(record{Op: opDrop, Bucket: key.(util.ID).String()})
 		}
 
-		return true
+		return err == nil
 	})
 
-	return items
+	return items, deletes, err
 }
 
-func (b *BucketMap) Query(id uint64, index string, query string) (string, bool) {
+func (b *BucketMap) drop(bucketId util.ID) {
+	b.buckets.Delete(bucketId)
+}
+
+func (b *BucketMap) Query(id util.ID, index string, query string) (Item, bool) {
 	value, ok := b.buckets.Load(id)
 	if !ok {
-		return "", false
+		return Item{}, false
 	}
 
 	split := strings.Split(query, " ")
 
 	bkt := value.(*bucket)
-OuterLoop:
-	for _, it := range bkt.items {
-		if it.Index == index {
-			z := 0
-		Loop:
-			for _, s := range split {
-				for _, sidx := range it.SecIdx[z:] {
-					z++
-					if s == sidx {
-						continue Loop
-					}
-				}
-				continue OuterLoop
-			}
+	bkt.lock.RLock()
+	defer bkt.lock.RUnlock()
 
-			// Should have matched
-			return it.Value, true
+	// The first item inserted whose key holds the words of the query in order, leaving out the expired items
+	now := time.Now()
+	for _, id := range bkt.index.search(index, split) {
+		i, ok := bkt.find(id)
+		if ok && !bkt.items[i].expired(now) {
+			return bkt.items[i].toItem(), true
 		}
 	}
 
-	return "", false
+	return Item{}, false
 }
 
-func (b *BucketMap) Snapshot() []Item {
+func (b *BucketMap) Search(id util.ID, index string, query string, after string, limit int) []Item {
+	value, ok := b.buckets.Load(id)
+	if !ok {
+		return nil
+	}
+
+	bkt := value.(*bucket)
+	bkt.lock.RLock()
+	defer bkt.lock.RUnlock()
+
+	now := time.Now()
 	items := make([]Item, 0)
+	if query == "" {
+		for _, it := range bkt.items {
+			if it.Index == index && it.Key > after && !it.expired(now) {
+				items = append(items, it.toItem())
+			}
+		}
+	}
+	for _, id := range bkt.index.search(index, strings.Fields(query)) {
+		i, ok := bkt.find(id)
+		if !ok || bkt.items[i].Key <= after || bkt.items[i].expired(now) {
+			continue
+		}
+
+		it := bkt.items[i]
+		items = append(items, it.toItem())
+	}
+
+	// Keys are unique within an index
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key < items[j].Key
+	})
+
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	return items
+}
+
+func (b *BucketMap) Rank(id util.ID, index string, q string, score store.Scorer, after query.Rank, limit int) []node.RankedItem {
+	value, ok := b.buckets.Load(id)
+	if !ok || limit < 1 {
+		return nil
+	}
+
+	bkt := value.(*bucket)
+	bkt.lock.RLock()
+	defer bkt.lock.RUnlock()
+
+	// The tokens of the keys are kept along with the items, so the items are scored in place
+	now := time.Now()
+	ranked := make([]node.RankedItem, 0, limit)
+	rank := func(it item) {
+		if it.expired(now) {
+			return
+		}
+
+		s, fuzzy, ok := score(it.SecIdx)
+		if !ok {
+			return
+		}
+
+		r := query.Rank{Fuzzy: fuzzy, Score: s, Key: it.Key}
+		if !after.Before(r) || (len(ranked) == limit && !r.Before(ranked[limit-1].Rank())) {
+			return
+		}
+		ranked = node.InsertRanked(ranked, node.RankedItem{Item: it.toItem(), Score: s, Fuzzy: fuzzy}, limit)
+	}
+
+	if q == "" {
+		for _, it := range bkt.items {
+			if it.Index == index {
+				rank(it)
+			}
+		}
+	}
+	for _, id := range bkt.index.search(index, strings.Fields(q)) {
+		if i, ok := bkt.find(id); ok {
+			rank(bkt.items[i])
+		}
+	}
+
+	return ranked
+}
+
+func (b *BucketMap) Cardinality(id util.ID, index string) int {
+	value, ok := b.buckets.Load(id)
+	if !ok {
+		return 0
+	}
+
+	bkt := value.(*bucket)
+	bkt.lock.RLock()
+	defer bkt.lock.RUnlock()
+
+	return bkt.cardinality[index]
+}
+
+func (b *BucketMap) Stats() store.Stats {
+	stats := store.Stats{}
 
 	b.buckets.Range(func(_, value any) bool {
 		bkt := value.(*bucket)
+		bkt.lock.RLock()
+		stats.Buckets++
 		for _, it := range bkt.items {
-			items = append(items, Item{
-				Index: it.Index,
-				Key:   it.Key,
-				Value: it.Value,
-			})
+			if node.IsFuzzyIndex(it.Index) || node.IsPrefixIndex(it.Index) {
+				continue
+			}
+			stats.Items++
+			stats.Keys += 1 / float64(len(analysis.Distinct(it.SecIdx)))
 		}
+		bkt.lock.RUnlock()
 		return true
 	})
 
-	return items
+	return stats
 }
 
 func (b *BucketMap) Debug() json.RawMessage {
 	type debugBucket struct {
-		Id            uint64   `json:"id"`
+		Id            util.ID  `json:"id"`
 		Items         []item   `json:"items"`
 		UniqueIndexes []string `json:"unique_indexes"`
+		Tombstones    []string `json:"tombstones,omitempty"`
 	}
 
 	var buckets []debugBucket
 	b.buckets.Range(func(key, value any) bool {
+		value.(*bucket).lock.RLock()
 		i := debugBucket{
-			Id:            key.(uint64),
-			Items:         value.(*bucket).items,
-			UniqueIndexes: nil,
+			Id:            key.(util.ID),
+			Items:         append([]item(nil), value.(*bucket).items...),
+			UniqueIndexes: make([]string, 0),
 		}
-
-		uq := make([]string, 0)
-		value.(*bucket).uniqueIndexes.Range(func(key, value any) bool {
-			uq = append(uq, key.(string))
-			return true
-		})
-		i.UniqueIndexes = uq
+		for k := range value.(*bucket).ids {
+			i.UniqueIndexes = append(i.UniqueIndexes, fmt.Sprintf("%s/%s", k.index, k.key))
+		}
+		for ts := range value.(*bucket).tombstones {
+			i.Tombstones = append(i.Tombstones, fmt.Sprintf("%s/%s", ts.index, ts.key))
+		}
+		value.(*bucket).lock.RUnlock()
 
 		buckets = append(buckets, i)
 		return true
@@ -0,0 +1,499 @@
+package bucketmap
+
+import (
+	"errors"
+	"fmt"
+	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/hlc"
+	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/query"
+	"github.com/yousuf64/chord-kv/store"
+	"github.com/yousuf64/chord-kv/util"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Query(t *testing.T) {
+	b := NewBucketMap(testSpace)
+	bucketId := util.NewID(1)
+
+	items := []node.InsertItem{
+		{Index: "lord", Key: "lord of the rings", Value: "1"},
+		{Index: "lord", Key: "the lord of the flies", Value: "2"},
+		{Index: "lord", Key: "lord lord of war", Value: "3"},
+		{Index: "there", Key: "there and back again", Value: "4"},
+	}
+	for _, item := range items {
+		err := b.Add(bucketId, item)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		index string
+		query string
+		value string
+	}{
+		{index: "lord", query: "lord", value: "1"},
+		{index: "lord", query: "lord rings", value: "1"},
+		{index: "lord", query: "the lord", value: "2"},
+		{index: "lord", query: "lord the flies", value: "2"},
+		{index: "lord", query: "lord lord war", value: "3"},
+		{index: "lord", query: "rings lord", value: ""},
+		{index: "lord", query: "back", value: ""},
+		{index: "there", query: "there again", value: "4"},
+		{index: "again", query: "again", value: ""},
+	}
+	for _, c := range cases {
+		item, ok := b.Query(bucketId, c.index, c.query)
+		if ok != (c.value != "") || item.Value != c.value {
+			t.Fatalf("[%s/%s] expected %q, got %q", c.index, c.query, c.value, item.Value)
+		}
+	}
+
+	// The next item in insertion order answers once the first one is removed
+	_, err := b.Remove(bucketId, "lord", "lord of the rings", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, _ := b.Query(bucketId, "lord", "lord of")
+	if item.Value != "2" {
+		t.Fatalf("expected 2 after the removal, got %q", item.Value)
+	}
+}
+
+// Test_QueryMatchesScan checks the inverted index against a linear scan of the items.
+func Test_QueryMatchesScan(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"the", "lord", "of", "rings", "hello", "world", "foo", "bar"}
+	sentence := func(n int) string {
+		s := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			s = append(s, words[rnd.Intn(len(words))])
+		}
+		return strings.Join(s, " ")
+	}
+
+	b := NewBucketMap(testSpace)
+	bucketId := util.NewID(1)
+	items := make([]node.InsertItem, 0)
+	for i := 0; i < 300; i++ {
+		item := node.InsertItem{Index: words[rnd.Intn(2)], Key: sentence(1 + rnd.Intn(6)), Value: fmt.Sprint(i)}
+		if b.Add(bucketId, item) == nil {
+			items = append(items, item)
+		}
+	}
+
+	// Remove every third item
+	kept := make([]node.InsertItem, 0, len(items))
+	for i, item := range items {
+		if i%3 == 0 {
+			_, _ = b.Remove(bucketId, item.Index, item.Key, item.Version)
+			continue
+		}
+		kept = append(kept, item)
+	}
+
+	scan := func(index string, query string) (string, bool) {
+		split := strings.Split(query, " ")
+	Items:
+		for _, item := range kept {
+			if item.Index != index {
+				continue
+			}
+
+			secIdx := strings.Split(item.Key, " ")
+			z := 0
+		Words:
+			for _, s := range split {
+				for z < len(secIdx) {
+					z++
+					if secIdx[z-1] == s {
+						continue Words
+					}
+				}
+				continue Items
+			}
+			return item.Value, true
+		}
+		return "", false
+	}
+
+	for i := 0; i < 1000; i++ {
+		index, query := words[rnd.Intn(2)], sentence(1+rnd.Intn(3))
+		expected, expectedOk := scan(index, query)
+		item, ok := b.Query(bucketId, index, query)
+		if item.Value != expected || ok != expectedOk {
+			t.Fatalf("[%s/%s] expected %q, got %q", index, query, expected, item.Value)
+		}
+	}
+}
+
+func Test_Rank(t *testing.T) {
+	b := NewBucketMap(testSpace)
+	bucketId := util.NewID(1)
+
+	keys := []string{"lord of the rings", "lord of war", "the lord of flies", "lord jim", "lord of light", "war and peace"}
+	for _, key := range keys {
+		err := b.Add(bucketId, node.InsertItem{Index: "lord", Key: key, Value: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = b.Add(bucketId, node.InsertItem{Index: "lady", Key: "lord of ladies", Value: "lady"})
+
+	// The keys holding "of" score by their shortness, the ones holding "war" match only fuzzily
+	score := func(tokens []string) (float64, bool, bool) {
+		matches := strings.Contains(" "+strings.Join(tokens, " ")+" ", " of ")
+		fuzzy := strings.Contains(strings.Join(tokens, " "), "war")
+		return 1 / float64(len(tokens)), fuzzy, matches
+	}
+
+	expected := []string{"lord of light", "lord of the rings", "the lord of flies", "lord of war"}
+	for _, q := range []string{"", "lord"} {
+		ranked := make([]string, 0)
+		after := query.Rank{Score: math.Inf(1)}
+		for {
+			page := b.Rank(bucketId, "lord", q, score, after, 3)
+			for _, r := range page {
+				ranked = append(ranked, r.Item.Key)
+			}
+			if len(page) < 3 {
+				break
+			}
+			after = page[len(page)-1].Rank()
+		}
+
+		if strings.Join(ranked, ",") != strings.Join(expected, ",") {
+			t.Fatalf("[%s] expected %v, got %v", q, expected, ranked)
+		}
+	}
+}
+
+func Test_Delete(t *testing.T) {
+	b := NewBucketMap(testSpace)
+	bucketId := util.NewID(1)
+	now := time.Now()
+
+	err := b.Add(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "1", Version: hlc.New(now, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existed, err := b.Delete(bucketId, "lord", "lord of the rings", hlc.New(now, 1))
+	if err != nil || !existed {
+		t.Fatalf("expected the item deleted: %v", err)
+	}
+	if _, ok := b.Query(bucketId, "lord", "lord"); ok {
+		t.Fatalf("deleted item still found")
+	}
+	if version, ok := b.Tombstone(bucketId, "lord", "lord of the rings"); !ok || version != hlc.New(now, 1) {
+		t.Fatalf("tombstone not left")
+	}
+
+	// Deleting an item never added leaves a tombstone all the same
+	existed, _ = b.Delete(util.NewID(2), "war", "war and peace", hlc.New(now, 0))
+	if _, ok := b.Tombstone(util.NewID(2), "war", "war and peace"); existed || !ok {
+		t.Fatalf("expected a tombstone of the missing item")
+	}
+
+	// Adding the item again clears the tombstone
+	err = b.Add(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "2", Version: hlc.New(now, 2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.Tombstone(bucketId, "lord", "lord of the rings"); ok {
+		t.Fatalf("tombstone not cleared")
+	}
+
+	// Compact drops the expired tombstones only
+	b.tombstoneTTL = time.Hour
+	_ = b.Compact()
+	if _, ok := b.Tombstone(util.NewID(2), "war", "war and peace"); !ok {
+		t.Fatalf("tombstone dropped before expiring")
+	}
+
+	b.tombstoneTTL = time.Nanosecond
+	time.Sleep(2 * time.Millisecond)
+	_ = b.Compact()
+	if _, ok := b.Tombstone(util.NewID(2), "war", "war and peace"); ok {
+		t.Fatalf("expired tombstone not dropped")
+	}
+}
+
+func Test_TombstoneRanges(t *testing.T) {
+	b := NewBucketMap(testSpace)
+	now := time.Now()
+
+	_, _ = b.Delete(util.NewID(1), "lord", "lord of the rings", hlc.New(now, 1))
+	_, _ = b.Delete(util.NewID(5), "war", "war and peace", hlc.New(now, 2))
+
+	// The tombstones travel along with the items of the ranges
+	items, deletes, _, _ := b.Scan(util.NewID(4), util.NewID(0), store.Cursor{}, 0)
+	if len(items) != 0 || len(deletes) != 1 || deletes[0].Key != "war and peace" || deletes[0].Version != hlc.New(now, 2) {
+		t.Fatalf("expected the tombstone outside the range, got %v", deletes)
+	}
+	if _, deletes, _, _ = b.Scan(util.NewID(0), util.NewID(0), store.Cursor{}, 0); len(deletes) != 2 {
+		t.Fatalf("expected all the tombstones in the scan of the whole ring, got %v", deletes)
+	}
+
+	// Forget drops the tombstone only when still of the version
+	forgotten, err := b.Forget(util.NewID(5), "war", "war and peace", hlc.New(now, 1))
+	if err != nil || forgotten {
+		t.Fatalf("expected a later tombstone kept: %v", err)
+	}
+	forgotten, err = b.Forget(util.NewID(5), "war", "war and peace", hlc.New(now, 2))
+	if _, ok := b.Tombstone(util.NewID(5), "war", "war and peace"); err != nil || !forgotten || ok {
+		t.Fatalf("expected the tombstone forgotten: %v", err)
+	}
+
+	_, deletes, err = b.GetAndDeleteRange(util.NewID(0), util.NewID(4))
+	if err != nil || len(deletes) != 1 || deletes[0].Key != "lord of the rings" {
+		t.Fatalf("expected the tombstone within the range, got %v: %v", deletes, err)
+	}
+	if _, ok := b.Tombstone(util.NewID(1), "lord", "lord of the rings"); ok {
+		t.Fatalf("tombstone of the range not removed")
+	}
+}
+
+func Test_Scan(t *testing.T) {
+	b := NewBucketMap(testSpace)
+	now := time.Now()
+
+	_ = b.Add(util.NewID(1), node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "1"})
+	_ = b.Add(util.NewID(1), node.InsertItem{Index: "lord", Key: "lord of war", Value: "2"})
+	_ = b.Add(util.NewID(2), node.InsertItem{Index: "war", Key: "war and peace", Value: "3"})
+	_, _ = b.Delete(util.NewID(2), "war", "war of the worlds", hlc.New(now, 1))
+	_ = b.Add(util.NewID(5), node.InsertItem{Index: "peace", Key: "war and peace", Value: "4"})
+
+	if count := b.Count(util.NewID(0), util.NewID(4)); count != 4 {
+		t.Fatalf("expected 4 items and tombstones within the range, got %d", count)
+	}
+
+	// The range is scanned in chunks, resuming from the cursor of the previous one
+	var keys []string
+	cursor := store.Cursor{}
+	for chunks := 1; ; chunks++ {
+		items, deletes, next, done := b.Scan(util.NewID(0), util.NewID(4), cursor, 3)
+		if len(items)+len(deletes) > 3 {
+			t.Fatalf("chunk exceeds the limit: %v %v", items, deletes)
+		}
+		for _, it := range items {
+			keys = append(keys, it.Key)
+		}
+		for _, it := range deletes {
+			keys = append(keys, "-"+it.Key)
+		}
+
+		if chunks == 1 {
+			// An item added behind the cursor is left for the next scan
+			_ = b.Add(util.NewID(1), node.InsertItem{Index: "lord", Key: "lord of the flies", Value: "5"})
+		}
+		if done {
+			break
+		}
+		cursor = next
+	}
+
+	expected := []string{"lord of the rings", "lord of war", "war and peace", "-war of the worlds"}
+	if fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, keys)
+	}
+}
+
+// Test_LastWriterWins checks that the writes and the deletions of an item settle on the latest version
+// whatever order they arrive in.
+func Test_LastWriterWins(t *testing.T) {
+	b := NewBucketMap(testSpace)
+	bucketId := util.NewID(1)
+	now := time.Now()
+
+	write := func(value string, version hlc.Timestamp) {
+		_, err := b.Put(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: value, Version: version})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(value string) {
+		t.Helper()
+		item, ok := b.Query(bucketId, "lord", "lord")
+		if ok != (value != "") || item.Value != value {
+			t.Fatalf("expected %q, got %q", value, item.Value)
+		}
+	}
+
+	write("2", hlc.New(now, 2))
+	write("1", hlc.New(now, 1))
+	expect("2")
+
+	// Ties resolve by the value, whichever arrives first
+	write("0", hlc.New(now, 2))
+	expect("2")
+	write("3", hlc.New(now, 2))
+	expect("3")
+
+	// An earlier deletion loses to the item
+	_, _ = b.Delete(bucketId, "lord", "lord of the rings", hlc.New(now, 1))
+	expect("3")
+
+	_, _ = b.Delete(bucketId, "lord", "lord of the rings", hlc.New(now, 4))
+	expect("")
+
+	// The tombstone holds off the writes preceding it
+	write("4", hlc.New(now, 3))
+	expect("")
+	err := b.Add(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "4", Version: hlc.New(now, 4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("")
+
+	write("5", hlc.New(now, 5))
+	expect("5")
+}
+
+func Test_Put(t *testing.T) {
+	b := NewBucketMap(testSpace)
+	bucketId := util.NewID(1)
+
+	existed, err := b.Replace(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "1"})
+	if err != nil || existed {
+		t.Fatalf("expected nothing to replace: %v", err)
+	}
+	if _, ok := b.Query(bucketId, "lord", "lord"); ok {
+		t.Fatalf("replace added a missing item")
+	}
+
+	existed, err = b.Put(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "1"})
+	if err != nil || existed {
+		t.Fatalf("expected the item created: %v", err)
+	}
+	_ = b.Add(bucketId, node.InsertItem{Index: "lord", Key: "lord of war", Value: "2"})
+
+	// The replaced item keeps its place in the insertion order
+	for i, value := range []string{"3", "4"} {
+		var err error
+		if i == 0 {
+			existed, err = b.Put(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: value})
+		} else {
+			existed, err = b.Replace(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: value})
+		}
+		if err != nil || !existed {
+			t.Fatalf("[%s] expected the item replaced: %v", value, err)
+		}
+
+		if got, _ := b.Query(bucketId, "lord", "lord"); got.Value != value {
+			t.Fatalf("[%s] expected the replaced value, got %q", value, got.Value)
+		}
+	}
+	if n := b.Cardinality(bucketId, "lord"); n != 2 {
+		t.Fatalf("expected 2 items, got %d", n)
+	}
+
+	// The items after a removed one are still found by their keys
+	_ = b.Add(bucketId, node.InsertItem{Index: "lord", Key: "lord jim", Value: "5"})
+	_, _ = b.Delete(bucketId, "lord", "lord of the rings", 0)
+	for _, key := range []string{"lord of war", "lord jim"} {
+		existed, err = b.Put(bucketId, node.InsertItem{Index: "lord", Key: key, Value: "6"})
+		if err != nil || !existed {
+			t.Fatalf("[%s] expected the item replaced: %v", key, err)
+		}
+	}
+	if n := b.Cardinality(bucketId, "lord"); n != 2 {
+		t.Fatalf("expected 2 items, got %d", n)
+	}
+}
+
+func Test_Swap(t *testing.T) {
+	b := NewBucketMap(testSpace)
+	bucketId := util.NewID(1)
+	now := time.Now()
+
+	existed, err := b.Swap(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "1", Version: hlc.New(now, 1)}, 0)
+	if err != nil || existed {
+		t.Fatalf("expected nothing to swap: %v", err)
+	}
+
+	_ = b.Add(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "1", Version: hlc.New(now, 1)})
+
+	existed, err = b.Swap(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "2", Version: hlc.New(now, 3)}, hlc.New(now, 0))
+	if !errors.Is(err, errs.VersionMismatchError) || !existed {
+		t.Fatalf("expected a version mismatch, got %v", err)
+	}
+
+	existed, err = b.Swap(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "2", Version: hlc.New(now, 2)}, hlc.New(now, 1))
+	if err != nil || !existed {
+		t.Fatalf("expected the item swapped: %v", err)
+	}
+	if item, _ := b.Query(bucketId, "lord", "lord"); item.Value != "2" || item.Version != hlc.New(now, 2) {
+		t.Fatalf("expected the swapped value, got %q", item.Value)
+	}
+
+	// The version swapped away no longer matches
+	_, err = b.Swap(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "3", Version: hlc.New(now, 3)}, hlc.New(now, 1))
+	if !errors.Is(err, errs.VersionMismatchError) {
+		t.Fatalf("expected a version mismatch, got %v", err)
+	}
+}
+
+func Test_Expire(t *testing.T) {
+	b := NewBucketMap(testSpace)
+	bucketId := util.NewID(1)
+	now := time.Now()
+
+	items := []node.InsertItem{
+		{Index: "lord", Key: "lord of the rings", Value: "1", ExpiresAt: now.Add(-time.Second)},
+		{Index: "lord", Key: "lord of war", Value: "2", ExpiresAt: now.Add(time.Hour)},
+		{Index: "lord", Key: "lord jim", Value: "3"},
+	}
+	for _, item := range items {
+		err := b.Add(bucketId, item)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The reads leave out the expired item before the reaper evicts it
+	if item, _ := b.Query(bucketId, "lord", "lord"); item.Value != "2" || !item.ExpiresAt.Equal(items[1].ExpiresAt) {
+		t.Fatalf("expected the first live item, got %q", item.Value)
+	}
+	if found := b.Search(bucketId, "lord", "", "", 10); len(found) != 2 {
+		t.Fatalf("expected 2 live items, got %d", len(found))
+	}
+
+	// The writes treat the expired item as missing
+	existed, err := b.Replace(bucketId, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "4"})
+	if err != nil || existed {
+		t.Fatalf("expected the expired item left unreplaced: %v", err)
+	}
+	if _, ok := b.version(bucketId, "lord", "lord of the rings"); ok {
+		t.Fatalf("expected no version of the expired item")
+	}
+
+	evicted, err := b.Expire(now)
+	if err != nil || evicted != 1 {
+		t.Fatalf("expected 1 item evicted, got %d: %v", evicted, err)
+	}
+	if n := b.Cardinality(bucketId, "lord"); n != 2 {
+		t.Fatalf("expected 2 items left, got %d", n)
+	}
+
+	evicted, _ = b.Expire(now.Add(2 * time.Hour))
+	if evicted != 1 {
+		t.Fatalf("expected the item expiring later evicted, got %d", evicted)
+	}
+
+	// An expired item awaiting the reaper makes way for the item created again
+	_ = b.Add(bucketId, node.InsertItem{Index: "war", Key: "war and peace", Value: "1", ExpiresAt: now.Add(-time.Second)})
+	err = b.Add(bucketId, node.InsertItem{Index: "war", Key: "war and peace", Value: "2"})
+	if err != nil {
+		t.Fatalf("expected the expired item replaced: %v", err)
+	}
+	if item, _ := b.Query(bucketId, "war", "war"); item.Value != "2" || !item.ExpiresAt.IsZero() {
+		t.Fatalf("expected the item created again, got %q", item.Value)
+	}
+}
@@ -0,0 +1,113 @@
+package bucketmap
+
+import (
+	"sort"
+)
+
+// posting refers to an item holding a secondary index, along with the positions of the secondary index in the key.
+type posting struct {
+	id        uint64
+	positions []int
+}
+
+// invertedIndex maps the secondary indexes of the items of a bucket to the items holding them.
+// The posting lists are sorted by the item IDs, which follow the insertion order.
+type invertedIndex map[string]map[string][]posting // Index -> Secondary Index -> Postings
+
+func (ii invertedIndex) add(it item) {
+	positions := map[string][]int{}
+	for pos, secIdx := range it.SecIdx {
+		positions[secIdx] = append(positions[secIdx], pos)
+	}
+
+	postings, ok := ii[it.Index]
+	if !ok {
+		postings = map[string][]posting{}
+		ii[it.Index] = postings
+	}
+
+	for secIdx, pos := range positions {
+		postings[secIdx] = append(postings[secIdx], posting{id: it.id, positions: pos})
+	}
+}
+
+func (ii invertedIndex) remove(it item) {
+	postings := ii[it.Index]
+	for _, secIdx := range it.SecIdx {
+		list := postings[secIdx]
+		i := sort.Search(len(list), func(i int) bool { return list[i].id >= it.id })
+		if i == len(list) || list[i].id != it.id {
+			// Repeated secondary index, already removed
+			continue
+		}
+
+		if len(list) == 1 {
+			delete(postings, secIdx)
+			continue
+		}
+		postings[secIdx] = append(list[:i], list[i+1:]...)
+	}
+
+	if len(postings) == 0 {
+		delete(ii, it.Index)
+	}
+}
+
+// search returns the IDs of the items of the index whose keys hold the secondary indexes in order, in insertion order.
+// Intersects the posting lists starting from the shortest one, then checks the order of the positions.
+func (ii invertedIndex) search(index string, secIdxs []string) []uint64 {
+	postings, ok := ii[index]
+	if !ok || len(secIdxs) == 0 {
+		return nil
+	}
+
+	lists := make([][]posting, 0, len(secIdxs))
+	for _, secIdx := range secIdxs {
+		list, ok := postings[secIdx]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, list)
+	}
+
+	shortest := 0
+	for i, list := range lists {
+		if len(list) < len(lists[shortest]) {
+			shortest = i
+		}
+	}
+
+	ids := make([]uint64, 0)
+	for _, candidate := range lists[shortest] {
+		positions := make([][]int, len(lists))
+		matched := true
+		for i, list := range lists {
+			j := sort.Search(len(list), func(j int) bool { return list[j].id >= candidate.id })
+			if j == len(list) || list[j].id != candidate.id {
+				matched = false
+				break
+			}
+			positions[i] = list[j].positions
+		}
+
+		if matched && inOrder(positions) {
+			ids = append(ids, candidate.id)
+		}
+	}
+
+	return ids
+}
+
+// inOrder reports whether a position can be picked from each of the position lists, each following the previous one.
+func inOrder(positions [][]int) bool {
+	last := -1
+	for _, pos := range positions {
+		i := sort.SearchInts(pos, last+1)
+		if i == len(pos) {
+			return false
+		}
+		last = pos[i]
+	}
+
+	return true
+}
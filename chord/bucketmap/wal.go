@@ -0,0 +1,344 @@
+package bucketmap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/yousuf64/chord-kv/analysis"
+	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/hlc"
+	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/util"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	walFile       = "wal.log"
+	snapshotFile  = "snapshot.json"
+	tokenizerFile = "tokenizer"
+)
+
+const (
+	opAdd    = "add"
+	opRemove = "remove"
+	opDrop   = "drop"
+	opDelete = "delete"
+	opPut    = "put"
+	opForget = "forget"
+)
+
+// record is an entry of the write-ahead log, also used for the items and the tombstones of a snapshot.
+type record struct {
+	Op     string `json:"op,omitempty"`
+	Bucket string `json:"bucket"`
+	Index  string `json:"index,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Value  string `json:"value,omitempty"`
+	// Version is the version of the item, or of the deletion for a tombstone.
+	Version uint64 `json:"version,omitempty"`
+	// ExpiresAt is the deadline of the item in milliseconds since the Unix epoch, 0 when it never expires.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+// Open creates a BucketMap persisted to the directory. Every mutation is appended to a write-ahead log,
+// which Compact folds into a snapshot. The items stored in the directory are recovered
+// by loading the snapshot and replaying the write-ahead log on top of it, tokenizing their keys anew.
+// Fails when the items were stored by a BucketMap of another tokenizer.
+func Open(space util.Space, dir string, tokenizer analysis.Tokenizer) (*BucketMap, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkTokenizer(dir, tokenizer)
+	if err != nil {
+		return nil, err
+	}
+
+	b := NewBucketMap(space)
+	b.dir = dir
+	b.tokenizer = tokenizer
+
+	err = b.loadSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the snapshot: %w", err)
+	}
+
+	err = b.replay()
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay the write-ahead log: %w", err)
+	}
+
+	b.wal, err = os.OpenFile(filepath.Join(dir, walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// checkTokenizer records the name of the tokenizer in the directory, failing when the items stored in it were
+// tokenized by another one. The items live under the indexes of their tokens throughout the ring, where the queries
+// tokenized by another tokenizer no longer look them up. The directories predating the record were tokenized
+// by analysis.Whitespace.
+func checkTokenizer(dir string, tokenizer analysis.Tokenizer) error {
+	path := filepath.Join(dir, tokenizerFile)
+
+	name := ""
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		name = string(data)
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	case exists(filepath.Join(dir, walFile)) || exists(filepath.Join(dir, snapshotFile)):
+		name = analysis.Whitespace{}.Name()
+	}
+
+	if name != "" && name != tokenizer.Name() {
+		return fmt.Errorf("the items in %s are tokenized by %s rather than %s", dir, name, tokenizer.Name())
+	}
+
+	return os.WriteFile(path, []byte(tokenizer.Name()), 0o644)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (b *BucketMap) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(b.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var records []record
+	err = json.Unmarshal(data, &records)
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		if rec.Op != opDelete {
+			rec.Op = opAdd
+		}
+		err = b.apply(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replay applies the records of the write-ahead log. A record torn by a crash midway through
+// appending it is truncated away, along with anything following it.
+func (b *BucketMap) replay() error {
+	path := filepath.Join(b.dir, walFile)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+	offset := int64(0)
+	replayed := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("replay: truncating a torn record at offset %d\n", offset)
+				return os.Truncate(path, offset)
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec record
+		if json.Unmarshal(line, &rec) != nil {
+			log.Printf("replay: truncating a corrupt record at offset %d\n", offset)
+			return os.Truncate(path, offset)
+		}
+
+		err = b.apply(rec)
+		if err != nil {
+			return err
+		}
+
+		offset += int64(len(line))
+		replayed++
+	}
+
+	log.Printf("replay: replayed %d records from %s\n", replayed, path)
+	return nil
+}
+
+func (b *BucketMap) apply(rec record) error {
+	bucketId, err := util.ParseID(rec.Bucket)
+	if err != nil {
+		return err
+	}
+
+	switch rec.Op {
+	case opAdd:
+		err = b.add(bucketId, node.InsertItem{Index: rec.Index, Key: rec.Key, Value: rec.Value, Version: hlc.Timestamp(rec.Version), ExpiresAt: util.FromUnixMilli(rec.ExpiresAt)})
+		if err != nil && !errors.Is(err, errs.AlreadyExistsError) && !errors.Is(err, errStale) {
+			return err
+		}
+	case opPut:
+		_, err = b.put(bucketId, node.InsertItem{Index: rec.Index, Key: rec.Key, Value: rec.Value, Version: hlc.Timestamp(rec.Version), ExpiresAt: util.FromUnixMilli(rec.ExpiresAt)}, false)
+		if err != nil && !errors.Is(err, errStale) {
+			return err
+		}
+	case opRemove:
+		b.remove(bucketId, rec.Index, rec.Key, hlc.Timestamp(rec.Version))
+	case opDrop:
+		b.drop(bucketId)
+	case opDelete:
+		_, _ = b.delete(bucketId, rec.Index, rec.Key, hlc.Timestamp(rec.Version))
+	case opForget:
+		b.forget(bucketId, rec.Index, rec.Key, hlc.Timestamp(rec.Version))
+	default:
+		return fmt.Errorf("unknown op %q", rec.Op)
+	}
+
+	return nil
+}
+
+// append writes the record to the write-ahead log and syncs it to the disk.
+// Expects the caller to hold walLock.
+func (b *BucketMap) append(rec record) error {
+	if b.wal == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.wal.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return b.wal.Sync()
+}
+
+// Compact drops the expired tombstones, writes the items and the rest of the tombstones to a new snapshot
+// and truncates the write-ahead log. A crash midway leaves either the old or the new snapshot in place,
+// and replaying the log on top of either of them yields the same items.
+func (b *BucketMap) Compact() error {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
+	b.expireTombstones(time.Now().Add(-b.tombstoneTTL))
+	if b.wal == nil {
+		return nil
+	}
+
+	// The expired items are left out of the snapshot
+	now := time.Now()
+	records := make([]record, 0)
+	b.buckets.Range(func(key, value any) bool {
+		bkt := value.(*bucket)
+		bkt.lock.RLock()
+		for _, it := range bkt.items {
+			if it.expired(now) {
+				continue
+			}
+			records = append(records, record{Bucket: key.(util.ID).String(), Index: it.Index, Key: it.Key, Value: it.Value, Version: uint64(it.Version), ExpiresAt: util.UnixMilli(it.ExpiresAt)})
+		}
+		for ts, version := range bkt.tombstones {
+			records = append(records, record{Op: opDelete, Bucket: key.(util.ID).String(), Index: ts.index, Key: ts.key, Version: uint64(version)})
+		}
+		bkt.lock.RUnlock()
+		return true
+	})
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(b.dir, snapshotFile+".tmp")
+	err = writeFileSync(tmp, data)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp, filepath.Join(b.dir, snapshotFile))
+	if err != nil {
+		return err
+	}
+
+	err = syncDir(b.dir)
+	if err != nil {
+		return err
+	}
+
+	err = b.wal.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	return b.wal.Sync()
+}
+
+// Close closes the write-ahead log. The BucketMap must not be mutated afterward.
+func (b *BucketMap) Close() error {
+	if b.wal == nil {
+		return nil
+	}
+
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+
+	return b.wal.Close()
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
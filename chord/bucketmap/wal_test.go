@@ -0,0 +1,237 @@
+package bucketmap
+
+import (
+	"github.com/yousuf64/chord-kv/analysis"
+	"github.com/yousuf64/chord-kv/hlc"
+	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/util"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testSpace = util.NewSpace(3, 8)
+
+func Test_Recovery(t *testing.T) {
+	dir := t.TempDir()
+
+	b, err := Open(testSpace, dir, analysis.Whitespace{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	add := func(b *BucketMap, index string, key string) {
+		err := b.Add(testSpace.Hash(index), node.InsertItem{Index: index, Key: key, Value: key})
+		if err != nil {
+			t.Fatalf("add %s failed: %v", key, err)
+		}
+	}
+
+	add(b, "hello", "hello world")
+	add(b, "lord", "lord of the rings")
+	add(b, "of", "lord of the rings")
+
+	// Compacted into the snapshot
+	err = b.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Logged after the snapshot
+	add(b, "foo", "foo bar")
+	_, err = b.Put(testSpace.Hash("lord"), node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "updated"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.Remove(testSpace.Hash("hello"), "hello", "hello world", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = b.GetAndDeleteRange(util.NewID(3), util.NewID(4)) // of -> 4
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Crash midway through appending a record
+	_ = b.Close()
+	f, err := os.OpenFile(filepath.Join(dir, walFile), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"op":"add","bucket":"1","ind`)
+	_ = f.Close()
+
+	b, err = Open(testSpace, dir, analysis.Whitespace{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{"hello": false, "lord": true, "of": false, "foo": true}
+	for index, present := range expected {
+		_, ok := b.Query(testSpace.Hash(index), index, index)
+		if ok != present {
+			t.Fatalf("[%s] expected present %v, got %v", index, present, ok)
+		}
+	}
+
+	if item, _ := b.Query(testSpace.Hash("lord"), "lord", "lord"); item.Value != "updated" {
+		t.Fatalf("expected the updated value, got %q", item.Value)
+	}
+
+	// The torn record is gone, so the log accepts new records
+	add(b, "bar", "foo bar")
+	_ = b.Close()
+
+	b, err = Open(testSpace, dir, analysis.Whitespace{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if _, ok := b.Query(testSpace.Hash("bar"), "bar", "bar"); !ok {
+		t.Fatalf("item added after the recovery is lost")
+	}
+}
+
+func Test_RecoverTombstones(t *testing.T) {
+	dir := t.TempDir()
+
+	b, err := Open(testSpace, dir, analysis.Whitespace{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lord, war := testSpace.Hash("lord"), testSpace.Hash("war")
+	_, _ = b.Delete(lord, "lord", "lord of the rings", hlc.New(time.Now(), 0))
+
+	// Compacted into the snapshot
+	err = b.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Logged after the snapshot
+	_, _ = b.Delete(war, "war", "war and peace", hlc.New(time.Now(), 1))
+	handedOff := hlc.New(time.Now(), 2)
+	_, _ = b.Delete(war, "war", "war of the worlds", handedOff)
+	_, _ = b.Forget(war, "war", "war of the worlds", handedOff)
+	_ = b.Close()
+
+	b, err = Open(testSpace, dir, analysis.Whitespace{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if version, ok := b.Tombstone(lord, "lord", "lord of the rings"); !ok || version.IsZero() {
+		t.Fatalf("tombstone of the snapshot lost")
+	}
+	if version, ok := b.Tombstone(war, "war", "war and peace"); !ok || version.IsZero() {
+		t.Fatalf("tombstone of the log lost")
+	}
+	if _, ok := b.Tombstone(war, "war", "war of the worlds"); ok {
+		t.Fatalf("forgotten tombstone recovered")
+	}
+}
+
+func Test_AppendFailure(t *testing.T) {
+	b, err := Open(testSpace, t.TempDir(), analysis.Whitespace{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	lord, war := testSpace.Hash("lord"), testSpace.Hash("war")
+	deleted := hlc.New(time.Now(), 0)
+	_, _ = b.Delete(lord, "lord", "lord of the rings", deleted)
+	_ = b.Add(war, node.InsertItem{Index: "war", Key: "war and peace", Value: "1", ExpiresAt: time.Now().Add(-time.Second)})
+
+	// The log fails the appends
+	_ = b.wal.Close()
+
+	err = b.Add(lord, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "1", Version: hlc.New(time.Now(), 1)})
+	if err == nil {
+		t.Fatalf("expected the add to fail")
+	}
+	if version, ok := b.Tombstone(lord, "lord", "lord of the rings"); !ok || version != deleted {
+		t.Fatalf("tombstone dropped by a failed add")
+	}
+
+	err = b.Add(war, node.InsertItem{Index: "war", Key: "war and peace", Value: "2"})
+	if err == nil {
+		t.Fatalf("expected the add to fail")
+	}
+	val, _ := b.buckets.Load(war)
+	if i := val.(*bucket).indexOf("war", "war and peace"); i < 0 || val.(*bucket).items[i].Value != "1" {
+		t.Fatalf("expired item dropped by a failed add")
+	}
+}
+
+func Test_RecoverDeadlines(t *testing.T) {
+	dir := t.TempDir()
+
+	b, err := Open(testSpace, dir, analysis.Whitespace{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lord, war := testSpace.Hash("lord"), testSpace.Hash("war")
+	deadline := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	_ = b.Add(lord, node.InsertItem{Index: "lord", Key: "lord of the rings", Value: "1", ExpiresAt: deadline})
+	_ = b.Add(war, node.InsertItem{Index: "war", Key: "war and peace", Value: "1", ExpiresAt: time.Now().Add(-time.Second)})
+
+	// Compacted into the snapshot, leaving out the expired item
+	err = b.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Logged after the snapshot
+	_, _ = b.Put(war, node.InsertItem{Index: "war", Key: "war and peace", Value: "2", ExpiresAt: deadline})
+	_ = b.Close()
+
+	b, err = Open(testSpace, dir, analysis.Whitespace{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if item, ok := b.Query(lord, "lord", "lord"); !ok || !item.ExpiresAt.Equal(deadline) {
+		t.Fatalf("deadline of the snapshot lost, got %v", item.ExpiresAt)
+	}
+	if item, ok := b.Query(war, "war", "war"); !ok || item.Value != "2" || !item.ExpiresAt.Equal(deadline) {
+		t.Fatalf("deadline of the log lost, got %v", item.ExpiresAt)
+	}
+}
+
+func Test_RecoverTokenizer(t *testing.T) {
+	dir := t.TempDir()
+
+	b, err := Open(testSpace, dir, analysis.NewStandard(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = b.Close()
+
+	_, err = Open(testSpace, dir, analysis.Whitespace{})
+	if err == nil {
+		t.Fatalf("expected the items tokenized by another tokenizer to fail")
+	}
+
+	// The directories predating the record of the tokenizer were tokenized by whitespace
+	legacy := t.TempDir()
+	err = os.WriteFile(filepath.Join(legacy, walFile), nil, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Open(testSpace, legacy, analysis.NewStandard(false))
+	if err == nil {
+		t.Fatalf("expected the legacy items to fail with the standard tokenizer")
+	}
+	b, err = Open(testSpace, legacy, analysis.Whitespace{})
+	if err != nil {
+		t.Fatalf("expected the legacy items to open with whitespace, got %v", err)
+	}
+	_ = b.Close()
+}
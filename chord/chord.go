@@ -2,15 +2,21 @@ package chord
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/yousuf64/chord-kv/analysis"
 	"github.com/yousuf64/chord-kv/chord/bucketmap"
 	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/hlc"
 	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/query"
+	"github.com/yousuf64/chord-kv/store"
 	"github.com/yousuf64/chord-kv/util"
 	"log"
-	"math"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -26,42 +32,226 @@ type ChordNode interface {
 
 	// DEBUG
 	Debug() string
+	DebugLookup(ctx context.Context, key string) string
+}
+
+// ProtocolVersion is the version of the Peer protocol spoken by the node.
+// Nodes only join the rings speaking the same protocol version. Version 3 ranks the searches
+// on the index nodes of their anchors. Version 4 carries the tombstones of the deleted items along with the handoffs
+// and the replicas, and drops the replicas of the nodes whose replica sets the node leaves. Version 5 resolves
+// the writes by their versions, which the nodes of the earlier versions ignore, and looks up the replicas by their keys
+// along with their tombstones.
+const ProtocolVersion = 5
+
+type Config struct {
+	// Space is the identifier space of the ring.
+	Space util.Space
+	// SuccessorListSize is the number of successors each node keeps track of, so that the ring survives
+	// the failure of up to SuccessorListSize-1 consecutive nodes.
+	SuccessorListSize int
+	// ReplicationFactor is the number of nodes holding a copy of each item, including the owner.
+	// The owner replicates its items to the next ReplicationFactor-1 successors.
+	ReplicationFactor int
+	// LookupMode selects how FindSuccessor walks the ring.
+	LookupMode LookupMode
+	// MaxLookupHops is the number of hops a lookup may take before giving up. Defaults to 2*M.
+	MaxLookupHops int
+	// LookupRetries is the number of times reads and writes retry a lookup that failed to converge,
+	// waiting LookupBackoff before the first retry and doubling the wait after each.
+	LookupRetries int
+	LookupBackoff time.Duration
+	// TransferChunkSize is the number of items sent in each chunk of a transfer.
+	TransferChunkSize int
+	// TransferInterval is the pause between the chunks of a transfer, throttling the transfers
+	// so that they do not starve the foreground requests. Zero sends the chunks back to back.
+	TransferInterval time.Duration
+	// Engine opens the stores of the node. Defaults to the bucket maps, persisted under DataDir if set.
+	Engine store.Engine
+	// DataDir is the directory persisting the buckets and the replicas of the node, under a subdirectory
+	// per node ID. The node keeps its items in memory only when empty.
+	DataDir string
+	// SnapshotInterval is the interval of compacting the write-ahead logs into snapshots and expiring the tombstones.
+	SnapshotInterval time.Duration
+	// ReapInterval is the interval of evicting the expired items from the buckets and the replicas.
+	ReapInterval time.Duration
+	// TombstoneTTL is how long the tombstones of the deleted items are kept, keeping the stale copies of the items
+	// in the handoffs and the replicas in flight from resurrecting them. Defaults to bucketmap.DefaultTombstoneTTL.
+	TombstoneTTL time.Duration
+	// Tokenizer splits the keys and the queries into tokens. The nodes of a ring have to agree on it.
+	// Defaults to the standard analyzer without stemming. A custom Engine has to tokenize the keys the same way.
+	Tokenizer analysis.Tokenizer
+	// Fuzzy indexes the deletion variants of the tokens of the keys inserted through the node, enabling the typo-tolerant
+	// searches at the cost of an item per letter of each token.
+	Fuzzy bool
+}
+
+// LookupMode is the strategy FindSuccessor resolves the successor of an ID with.
+type LookupMode int
+
+const (
+	// RecursiveLookup forwards the lookup to the closest preceding node, which forwards it further,
+	// keeping an open call at every hop.
+	RecursiveLookup LookupMode = iota
+	// IterativeLookup has the origin node ask each hop for the next one and drive the walk itself.
+	IterativeLookup
+)
+
+func ParseLookupMode(s string) (LookupMode, error) {
+	switch strings.ToLower(s) {
+	case "", "recursive":
+		return RecursiveLookup, nil
+	case "iterative":
+		return IterativeLookup, nil
+	default:
+		return RecursiveLookup, fmt.Errorf("unknown lookup mode %q", s)
+	}
+}
+
+func (m LookupMode) String() string {
+	if m == IterativeLookup {
+		return "iterative"
+	}
+	return "recursive"
+}
+
+// Lookup is the result of an iterative lookup.
+type Lookup struct {
+	// Successor is the node responsible for the ID.
+	Successor node.Node
+	// Path holds the nodes asked for the next hop, starting with the origin node.
+	Path []node.Node
+	// Hops is the number of remote nodes asked during the lookup.
+	Hops int
+}
+
+// requiredReplicas returns the number of replicas, including the owner, that have to acknowledge
+// a write or respond to a read to satisfy the consistency level.
+func (cfg Config) requiredReplicas(consistency node.Consistency) int {
+	switch consistency {
+	case node.Quorum:
+		return cfg.ReplicationFactor/2 + 1
+	case node.All:
+		return cfg.ReplicationFactor
+	default:
+		return 1
+	}
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Space:             util.NewSpace(3, 9),
+		SuccessorListSize: 3,
+		ReplicationFactor: 2,
+		LookupRetries:     3,
+		LookupBackoff:     100 * time.Millisecond,
+		TransferChunkSize: 1000,
+		SnapshotInterval:  time.Minute,
+		ReapInterval:      10 * time.Second,
+		Tokenizer:         analysis.NewStandard(false),
+	}
 }
 
 type Chord struct {
-	id              uint64
+	id              util.ID
 	addr            string
+	cfg             Config
 	successor       node.Node
+	successors      []node.Node
 	predecessor     node.Node
 	finger          []node.Node
-	fingerIdx       []uint64
-	bm              *bucketmap.BucketMap
+	fingerIdx       []util.ID
+	bm              store.Store
+	replicas        store.Store
 	stopChan        chan struct{}
 	wg              sync.WaitGroup
 	successorLock   sync.Mutex
+	successorsLock  sync.RWMutex
 	predecessorLock sync.Mutex
+	peers           sync.Map // Address -> node.Handshake
+	handoffs        map[string]pendingHandoff
+	handoffSeq      uint64
+	handoffLock     sync.Mutex
+	stopOnce        sync.Once
+	transfers       sync.Map // Handoff ID -> node.TransferChunk, the progress of the incoming transfers
+	clock           *hlc.Clock
+
+	// predecessorBound is the predecessor of the predecessor as of the last health check, which bounds the range
+	// of the replicas taken over when the predecessor fails. Guarded by predecessorLock.
+	predecessorBound node.Node
+	// replicaSet is the successors holding the replicas of the node's range as of the last repair.
+	replicaSet []node.Node
+}
+
+func NewChord(addr string, cfg Config) *Chord {
+	return newChord(addr, cfg.Space.Hash(addr), cfg)
 }
 
-func NewChord(addr string) *Chord {
+func newChord(addr string, id util.ID, cfg Config) *Chord {
+	if cfg.ReplicationFactor < 1 {
+		cfg.ReplicationFactor = 1
+	}
+	if cfg.SuccessorListSize < cfg.ReplicationFactor-1 {
+		cfg.SuccessorListSize = cfg.ReplicationFactor - 1
+	}
+	if cfg.SuccessorListSize < 1 {
+		cfg.SuccessorListSize = 1
+	}
+	if cfg.TransferChunkSize < 1 {
+		cfg.TransferChunkSize = 1
+	}
+	if cfg.SnapshotInterval <= 0 {
+		cfg.SnapshotInterval = time.Minute
+	}
+	if cfg.ReapInterval <= 0 {
+		cfg.ReapInterval = 10 * time.Second
+	}
+	if cfg.Tokenizer == nil {
+		cfg.Tokenizer = analysis.NewStandard(false)
+	}
+	if cfg.MaxLookupHops < 1 {
+		cfg.MaxLookupHops = 2 * cfg.Space.M
+	}
+
 	c := &Chord{
-		id:              util.Hash(addr),
+		id:              id,
 		addr:            addr,
+		cfg:             cfg,
 		successor:       nil,
 		predecessor:     nil,
-		finger:          make([]node.Node, util.M),
-		fingerIdx:       make([]uint64, util.M),
-		bm:              bucketmap.NewBucketMap(),
+		finger:          make([]node.Node, cfg.Space.M),
+		fingerIdx:       make([]util.ID, cfg.Space.M),
+		bm:              openStore(cfg, id, "buckets"),
+		replicas:        openStore(cfg, id, "replicas"),
 		stopChan:        make(chan struct{}),
 		wg:              sync.WaitGroup{},
 		successorLock:   sync.Mutex{},
+		successorsLock:  sync.RWMutex{},
 		predecessorLock: sync.Mutex{},
+		handoffs:        map[string]pendingHandoff{},
+		clock:           hlc.NewClock(),
 	}
 	c.successor = c
+	c.successors = []node.Node{c}
 
 	return c
 }
 
-func (c *Chord) ID() uint64 {
+// openStore opens the store of the node with the engine of the config.
+func openStore(cfg Config, id util.ID, name string) store.Store {
+	engine := cfg.Engine
+	if engine == nil {
+		engine = bucketmap.Engine{Dir: cfg.DataDir, Tokenizer: cfg.Tokenizer, TombstoneTTL: cfg.TombstoneTTL}
+	}
+
+	s, err := engine.Open(cfg.Space, id, name)
+	if err != nil {
+		panic(fmt.Sprintf("failed to open the %s of node [%s]: %v", name, id, err))
+	}
+
+	return s
+}
+
+func (c *Chord) ID() util.ID {
 	return c.id
 }
 
@@ -69,8 +259,120 @@ func (c *Chord) Addr() string {
 	return c.addr
 }
 
-func (c *Chord) FindSuccessor(ctx context.Context, id uint64) (node.Node, error) {
-	if util.Between(id, c.id, c.successor.ID()) {
+// Handshake validates the handshake of a peer against the ring of the node and replies with the node's handshake.
+func (c *Chord) Handshake(_ context.Context, hs node.Handshake) (node.Handshake, error) {
+	err := c.compatible(hs)
+	if err != nil {
+		return node.Handshake{}, err
+	}
+
+	return c.handshake(), nil
+}
+
+func (c *Chord) handshake() node.Handshake {
+	capabilities := []string{node.CapabilityConsistency, node.CapabilityVirtualNodes, node.CapabilityStreaming}
+	if c.cfg.ReplicationFactor > 1 {
+		capabilities = append(capabilities, node.CapabilityReplication)
+	}
+	if c.cfg.Fuzzy {
+		capabilities = append(capabilities, node.CapabilityFuzzy)
+	}
+
+	return node.Handshake{
+		M:                 c.cfg.Space.M,
+		RingSize:          c.cfg.Space.RingSize,
+		Hash:              util.HashFunction,
+		ProtocolVersion:   ProtocolVersion,
+		Capabilities:      capabilities,
+		ReplicationFactor: c.cfg.ReplicationFactor,
+		Tokenizer:         c.cfg.Tokenizer.Name(),
+	}
+}
+
+// Tokenizer returns the tokenizer the node tokenizes the keys with, which the queries have to be tokenized with as well.
+func (c *Chord) Tokenizer() analysis.Tokenizer {
+	return c.cfg.Tokenizer
+}
+
+// Clock returns the hybrid logical clock versioning the writes coordinated by the node.
+func (c *Chord) Clock() *hlc.Clock {
+	return c.clock
+}
+
+// stamp returns the version of a write, stamping the unversioned writes with the clock of the node.
+// The clock observes the versions stamped by the other nodes, keeping the writes coordinated by the node after them.
+func (c *Chord) stamp(version hlc.Timestamp) hlc.Timestamp {
+	if version.IsZero() {
+		return c.clock.Now()
+	}
+
+	c.clock.Update(version)
+	return version
+}
+
+// Fuzzy reports whether the node indexes the deletion variants of the tokens for the typo-tolerant searches.
+func (c *Chord) Fuzzy() bool {
+	return c.cfg.Fuzzy
+}
+
+// compatible rejects the peers speaking a different protocol, belonging to a different identifier space
+// or tokenizing the keys differently. The fuzzy indexing has to agree as well, otherwise the writes coordinated
+// by a node without it leave the deletion variants of the other nodes behind, and so does the replication factor,
+// otherwise the owners replicating to fewer successors leave the replica sets the others expect short.
+func (c *Chord) compatible(hs node.Handshake) error {
+	if hs.ProtocolVersion != ProtocolVersion {
+		return fmt.Errorf("%w: protocol version %d, expected %d", errs.IncompatiblePeerError, hs.ProtocolVersion, ProtocolVersion)
+	}
+	if hs.Hash != util.HashFunction {
+		return fmt.Errorf("%w: hash function %s, expected %s", errs.IncompatiblePeerError, hs.Hash, util.HashFunction)
+	}
+	if hs.M != c.cfg.Space.M {
+		return fmt.Errorf("%w: M %d, expected %d", errs.IncompatiblePeerError, hs.M, c.cfg.Space.M)
+	}
+	if hs.RingSize == nil || hs.RingSize.Cmp(c.cfg.Space.RingSize) != 0 {
+		return fmt.Errorf("%w: ring size %s, expected %s", errs.IncompatiblePeerError, hs.RingSize, c.cfg.Space.RingSize)
+	}
+	if tokenizer := hs.TokenizerName(); tokenizer != c.cfg.Tokenizer.Name() {
+		return fmt.Errorf("%w: tokenizer %s, expected %s", errs.IncompatiblePeerError, tokenizer, c.cfg.Tokenizer.Name())
+	}
+	if fuzzy := hs.Supports(node.CapabilityFuzzy); fuzzy != c.cfg.Fuzzy {
+		return fmt.Errorf("%w: fuzzy %t, expected %t", errs.IncompatiblePeerError, fuzzy, c.cfg.Fuzzy)
+	}
+	if hs.ReplicationFactor != c.cfg.ReplicationFactor {
+		return fmt.Errorf("%w: replication factor %d, expected %d", errs.IncompatiblePeerError, hs.ReplicationFactor, c.cfg.ReplicationFactor)
+	}
+
+	return nil
+}
+
+// PeerSupports reports whether the peer advertised the capability during the handshake.
+func (c *Chord) PeerSupports(n node.Node, capability string) bool {
+	hs, ok := c.peers.Load(n.Addr())
+	if !ok {
+		return false
+	}
+
+	return hs.(node.Handshake).Supports(capability)
+}
+
+func (c *Chord) FindSuccessor(ctx context.Context, id util.ID, route node.Route) (node.Node, error) {
+	if route.HasVisited(c.ID()) {
+		return nil, errs.LookupLoopError
+	}
+	if route.TTL <= 0 {
+		return nil, errs.LookupHopLimitError
+	}
+
+	if c.cfg.LookupMode == IterativeLookup {
+		lookup, err := c.Lookup(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		return lookup.Successor, nil
+	}
+
+	if c.cfg.Space.Between(id, c.id, c.successor.ID()) {
 		return c.successor, nil
 	}
 
@@ -82,174 +384,1259 @@ func (c *Chord) FindSuccessor(ctx context.Context, id uint64) (node.Node, error)
 		return closestNode, nil
 	}
 
-	return closestNode.FindSuccessor(ctx, id)
+	return closestNode.FindSuccessor(ctx, id, route.Visit(c.ID()))
+}
+
+// route starts the route of a lookup originating from the node.
+func (c *Chord) route() node.Route {
+	return node.Route{TTL: c.cfg.MaxLookupHops}
+}
+
+// findSuccessor resolves the successor of the id, retrying after a backoff
+// when the lookup fails to converge while the ring stabilizes.
+func (c *Chord) findSuccessor(ctx context.Context, id util.ID) (node.Node, error) {
+	return c.findSuccessorVia(ctx, c, id)
+}
+
+// findSuccessorVia resolves the successor of the id starting the lookup from the node, retrying as findSuccessor does.
+func (c *Chord) findSuccessorVia(ctx context.Context, n node.Node, id util.ID) (node.Node, error) {
+	backoff := c.cfg.LookupBackoff
+	for attempt := 0; ; attempt++ {
+		successor, err := n.FindSuccessor(ctx, id, c.route())
+		if err == nil || attempt >= c.cfg.LookupRetries ||
+			!(errors.Is(err, errs.LookupLoopError) || errors.Is(err, errs.LookupHopLimitError)) {
+			return successor, err
+		}
+
+		log.Printf("findSuccessor: lookup of %s failed: %v, retrying in %s\n", id, err, backoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Chord) NextHop(_ context.Context, id util.ID) (node.Node, bool, error) {
+	if c.cfg.Space.Between(id, c.id, c.successor.ID()) {
+		return c.successor, true, nil
+	}
+
+	closestNode := c.closestPrecedingNode(id)
+	if closestNode.ID() == c.ID() {
+		return c, true, nil
+	}
+	if closestNode.ID() == id {
+		return closestNode, true, nil
+	}
+
+	return closestNode, false, nil
+}
+
+// Lookup resolves the successor of the id iteratively, asking each hop for the next one
+// instead of forwarding the lookup through the ring.
+func (c *Chord) Lookup(ctx context.Context, id util.ID) (Lookup, error) {
+	lookup := Lookup{Path: []node.Node{c}}
+	visited := map[util.ID]struct{}{c.ID(): {}}
+
+	var current node.Node = c
+	for {
+		if err := ctx.Err(); err != nil {
+			return lookup, err
+		}
+		if lookup.Hops >= c.cfg.MaxLookupHops {
+			return lookup, errs.LookupHopLimitError
+		}
+
+		next, done, err := current.NextHop(ctx, id)
+		if err != nil {
+			return lookup, err
+		}
+
+		if done {
+			lookup.Successor = next
+			return lookup, nil
+		}
+
+		if _, ok := visited[next.ID()]; ok {
+			return lookup, errs.LookupLoopError
+		}
+		visited[next.ID()] = struct{}{}
+
+		current = next
+		lookup.Path = append(lookup.Path, next)
+		lookup.Hops++
+	}
+}
+
+func (c *Chord) SetSuccessor(_ context.Context, successor node.Node) error {
+	c.successorLock.Lock()
+	defer c.successorLock.Unlock()
+
+	if successor.ID() == c.ID() {
+		c.successor = c
+		log.Println("SetSuccessor: successor set to myself")
+	} else {
+		c.successor = successor
+		log.Println("SetSuccessor: successor set to", c.successor.ID())
+	}
+	c.resetSuccessorList()
+
+	return nil
+}
+
+// GetSuccessorList returns the successor list of the node, nearest successor first.
+func (c *Chord) GetSuccessorList(_ context.Context) ([]node.Node, error) {
+	c.successorsLock.RLock()
+	defer c.successorsLock.RUnlock()
+
+	successors := make([]node.Node, len(c.successors))
+	copy(successors, c.successors)
+	return successors, nil
+}
+
+// resetSuccessorList discards the successor list and restarts it from the current successor.
+// Expects the caller to hold successorLock.
+func (c *Chord) resetSuccessorList() {
+	c.successorsLock.Lock()
+	defer c.successorsLock.Unlock()
+
+	c.successors = []node.Node{c.successor}
+}
+
+// refreshSuccessorList rebuilds the successor list from the current successor and its successor list.
+// Expects the caller to hold successorLock.
+func (c *Chord) refreshSuccessorList(ctx context.Context) error {
+	successors := []node.Node{c.successor}
+
+	if c.successor.ID() != c.ID() {
+		list, err := c.successor.GetSuccessorList(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range list {
+			if len(successors) >= c.cfg.SuccessorListSize || s.ID() == c.ID() {
+				break
+			}
+			if s.ID() == successors[len(successors)-1].ID() {
+				continue
+			}
+
+			successors = append(successors, s)
+		}
+	}
+
+	c.successorsLock.Lock()
+	c.successors = successors
+	c.successorsLock.Unlock()
+
+	return nil
+}
+
+// skipFailedSuccessor replaces the current successor with the first live node in the successor list.
+// Falls back to the node itself when none of the successors are reachable.
+// Expects the caller to hold successorLock.
+func (c *Chord) skipFailedSuccessor(ctx context.Context) {
+	failed := c.successor
+
+	c.successorsLock.Lock()
+	defer c.successorsLock.Unlock()
+
+	for i, s := range c.successors {
+		if s.ID() == failed.ID() || s.ID() == c.ID() {
+			continue
+		}
+
+		if err := s.Healthz(ctx); err != nil {
+			continue
+		}
+
+		log.Printf("skipFailedSuccessor: successor set from %s to %s\n", failed.ID(), s.ID())
+		c.successor = s
+		c.successors = c.successors[i:]
+		return
+	}
+
+	log.Printf("skipFailedSuccessor: no live successors left, successor set from %s to myself\n", failed.ID())
+	c.successor = c
+	c.successors = []node.Node{c}
+}
+
+func (c *Chord) SetPredecessor(_ context.Context, predecessor node.Node) error {
+	c.predecessorLock.Lock()
+	defer c.predecessorLock.Unlock()
+
+	if c.ID() == predecessor.ID() {
+		if c.predecessor != nil {
+			log.Printf("SetPredecessor: setting the predecessor from %s to <nil>\n", c.predecessor.ID())
+			c.predecessor = nil
+		}
+
+		return nil
+	}
+
+	if c.predecessor != nil {
+		log.Printf("SetPredecessor: setting the predecessor from %s to %s\n", c.predecessor.ID(), predecessor.ID())
+	} else {
+		log.Printf("SetPredecessor: setting the predecessor from <nil> to %s\n", predecessor.ID())
+	}
+
+	c.predecessor = predecessor
+	return nil
+}
+
+func (c *Chord) closestPrecedingNode(id util.ID) node.Node {
+	for i := c.cfg.Space.M - 1; i >= 0; i-- {
+		if c.finger[i] != nil && c.cfg.Space.Between(c.finger[i].ID(), c.ID(), id) {
+			return c.finger[i]
+		}
+	}
+
+	return c
+}
+
+// InsertBatch locally stores the items having the Index hash within the range of node's and its predecessor's ID.
+// Forwards the rest of the items to the correct successor. The write mode of each item tells how it treats
+// the item stored already.
+func (c *Chord) InsertBatch(ctx context.Context, consistency node.Consistency, items ...node.InsertItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	itemsById := map[util.ID][]node.InsertItem{}
+	for _, item := range items {
+		id := c.cfg.Space.Hash(item.Index)
+		if _, ok := itemsById[id]; !ok {
+			itemsById[id] = make([]node.InsertItem, 0)
+		}
+
+		itemsById[id] = append(itemsById[id], item)
+	}
+
+	for id, its := range itemsById {
+		if c.predecessor != nil && c.cfg.Space.Between(id, c.predecessor.ID(), c.ID()) {
+			err := c.insertLocal(ctx, consistency, its)
+			if err != nil {
+				return err
+			}
+		} else {
+			successor, err := c.findSuccessor(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			if successor.ID() == c.ID() {
+				err = c.insertLocal(ctx, consistency, its)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			err = successor.InsertBatch(ctx, consistency, its...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// CompareAndSwap swaps the item locally when the Index hash is within the range of node's and its predecessor's ID,
+// and forwards it to the correct successor otherwise.
+func (c *Chord) CompareAndSwap(ctx context.Context, consistency node.Consistency, item node.InsertItem, expected hlc.Timestamp) error {
+	id := c.cfg.Space.Hash(item.Index)
+	if c.predecessor != nil && c.cfg.Space.Between(id, c.predecessor.ID(), c.ID()) {
+		return c.swapLocal(ctx, consistency, item, expected)
+	}
+
+	successor, err := c.findSuccessor(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if successor.ID() == c.ID() {
+		return c.swapLocal(ctx, consistency, item, expected)
+	}
+
+	return successor.CompareAndSwap(ctx, consistency, item, expected)
+}
+
+// swapLocal swaps the item in the node's buckets and replicates it once swapped.
+func (c *Chord) swapLocal(ctx context.Context, consistency node.Consistency, item node.InsertItem, expected hlc.Timestamp) error {
+	if !c.clock.Plausible(expected) {
+		return fmt.Errorf("%w: version %s is ahead of the clock", errs.VersionMismatchError, expected)
+	}
+
+	// The version of the item follows the one it replaces. The clock observes it only once the swap matched,
+	// so that the versions no item holds do not drag the clock along
+	if item.Version.IsZero() {
+		item.Version = max(c.clock.Now(), expected+1)
+	}
+
+	existed, err := c.bm.Swap(c.cfg.Space.Hash(item.Index), item, expected)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		return errs.NotFoundError
+	}
+	c.clock.Update(item.Version)
+
+	return c.replicate(ctx, consistency, []node.InsertItem{item})
+}
+
+// DeleteBatch locally deletes the items having the Index hash within the range of node's and its predecessor's ID.
+// Forwards the rest of the items to the correct successor. Returns the number of the items that existed.
+func (c *Chord) DeleteBatch(ctx context.Context, consistency node.Consistency, items ...node.DeleteItem) (int, error) {
+	itemsById := map[util.ID][]node.DeleteItem{}
+	for _, item := range items {
+		id := c.cfg.Space.Hash(item.Index)
+		itemsById[id] = append(itemsById[id], item)
+	}
+
+	deleted := 0
+	for id, its := range itemsById {
+		if c.predecessor != nil && c.cfg.Space.Between(id, c.predecessor.ID(), c.ID()) {
+			n, err := c.deleteLocal(ctx, consistency, its)
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+			continue
+		}
+
+		successor, err := c.findSuccessor(ctx, id)
+		if err != nil {
+			return deleted, err
+		}
+
+		var n int
+		if successor.ID() == c.ID() {
+			n, err = c.deleteLocal(ctx, consistency, its)
+		} else {
+			n, err = successor.DeleteBatch(ctx, consistency, its...)
+		}
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+
+	return deleted, nil
+}
+
+// deleteLocal deletes the items from the node's buckets and replicates the deletions.
+// The items are deleted from the replicas as well, since the node serves from the replicas while its predecessor
+// is down, and keeps on holding the items it hands off as replicas.
+// The unversioned deletions are stamped after the versions of the items held, so that they delete the items
+// written by the nodes whose clocks run ahead instead of losing to them.
+func (c *Chord) deleteLocal(ctx context.Context, consistency node.Consistency, items []node.DeleteItem) (int, error) {
+	stamped := make([]node.DeleteItem, 0, len(items))
+	for _, item := range items {
+		if item.Version.IsZero() {
+			held, _ := lookup(c.cfg.Space.Hash(item.Index), item.Index, item.Key, c.bm, c.replicas)
+			c.clock.Update(held.Version)
+		}
+		item.Version = c.stamp(item.Version)
+		stamped = append(stamped, item)
+	}
+	items = stamped
+
+	deleted := 0
+	for _, item := range items {
+		id := c.cfg.Space.Hash(item.Index)
+		existed, err := c.bm.Delete(id, item.Index, item.Key, item.Version)
+		if err != nil {
+			return deleted, err
+		}
+
+		replicated, err := c.replicas.Delete(id, item.Index, item.Key, item.Version)
+		if err != nil {
+			return deleted, err
+		}
+
+		if existed || replicated {
+			deleted++
+		}
+	}
+
+	return deleted, c.replicateDeletes(ctx, consistency, items)
+}
+
+// DeleteReplica deletes the replicas held on behalf of one of the predecessors. The items are deleted from the node's
+// buckets as well, in case the node still holds them pending a handoff to the predecessor.
+func (c *Chord) DeleteReplica(_ context.Context, items ...node.DeleteItem) error {
+	for _, item := range items {
+		c.clock.Update(item.Version)
+		id := c.cfg.Space.Hash(item.Index)
+		_, err := c.replicas.Delete(id, item.Index, item.Key, item.Version)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.bm.Delete(id, item.Index, item.Key, item.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Chord) Query(ctx context.Context, index string, query string, key string, consistency node.Consistency) (node.InsertItem, error) {
+	id := c.cfg.Space.Hash(index)
+	if c.predecessor != nil && c.cfg.Space.Between(id, c.predecessor.ID(), c.ID()) {
+		return c.queryConsistent(ctx, id, index, query, key, consistency)
+	} else {
+		successor, err := c.findSuccessor(ctx, id)
+		if err != nil {
+			return node.InsertItem{}, err
+		}
+
+		if successor.ID() == c.ID() {
+			return c.queryConsistent(ctx, id, index, query, key, consistency)
+		}
+
+		item, err := successor.Query(ctx, index, query, key, consistency)
+		if err != nil {
+			return node.InsertItem{}, err
+		}
+		return item, nil
+	}
+}
+
+// maxQueryRounds bounds the keys a consistent query reconciles before giving up on finding a live match.
+const maxQueryRounds = 8
+
+// queryConsistent queries the local buckets and as many replicas as required by the consistency level.
+// Each round settles on a key matching the query and reconciles its copies by last-writer-wins, deletions included.
+// A key deleted by the latest version gets its tombstone spread to the copies, and the next round moves on to the next match.
+// Given a key, it reconciles the copies of the key alone.
+func (c *Chord) queryConsistent(ctx context.Context, id util.ID, index string, query string, key string, consistency node.Consistency) (node.InsertItem, error) {
+	required := c.cfg.requiredReplicas(consistency)
+	if required <= 1 {
+		if key != "" {
+			return c.getLocal(id, index, key)
+		}
+		return c.queryLocal(id, index, query)
+	}
+
+	for round := 0; round < maxQueryRounds; round++ {
+		candidate := key
+		if candidate == "" {
+			var err error
+			candidate, err = c.candidate(ctx, id, index, query, required)
+			if err != nil {
+				return node.InsertItem{}, err
+			}
+		}
+
+		item, deleted, err := c.reconcile(ctx, id, index, query, candidate, required)
+		if err != nil {
+			return node.InsertItem{}, err
+		}
+		if item.Key != "" && (deleted.IsZero() || item.Version > deleted) {
+			return item, nil
+		}
+
+		if !deleted.IsZero() {
+			err = c.repair(ctx, id, node.DeleteItem{Index: index, Key: candidate, Version: deleted})
+			if err != nil {
+				return node.InsertItem{}, err
+			}
+		}
+		if key != "" {
+			return node.InsertItem{}, errs.NotFoundError
+		}
+		// Either deleted or removed in the meantime, move on to the next match
+	}
+
+	return node.InsertItem{}, errs.NotFoundError
+}
+
+// candidate returns the key of the first item matching the query held by the node,
+// or by the first replica holding one when the node holds none.
+func (c *Chord) candidate(ctx context.Context, id util.ID, index string, query string, required int) (string, error) {
+	item, err := c.queryLocal(id, index, query)
+	if err == nil {
+		return item.Key, nil
+	}
+
+	responses := 1
+	successors, _ := c.GetSuccessorList(ctx)
+	for i, s := range successors {
+		if responses >= required || i >= c.cfg.ReplicationFactor-1 || s.ID() == c.ID() {
+			break
+		}
+
+		replica, _, err := s.QueryReplica(ctx, index, query, "")
+		if err == nil {
+			return replica.Key, nil
+		} else if errors.Is(err, errs.NotFoundError) {
+			responses++
+		} else {
+			log.Printf("queryConsistent: failed to query the replica at %s: %v\n", s.ID(), err)
+		}
+	}
+
+	if responses < required {
+		return "", errs.InsufficientReplicasError
+	}
+
+	return "", errs.NotFoundError
+}
+
+// reconcile returns the latest copy of the item with the index and the key held by the node and the replicas
+// consulted, along with the version of the latest tombstone of the item. Leaves the item empty when none of them hold it.
+func (c *Chord) reconcile(ctx context.Context, id util.ID, index string, query string, key string, required int) (node.InsertItem, hlc.Timestamp, error) {
+	stores := []store.Store{c.bm}
+	if c.predecessor == nil {
+		stores = append(stores, c.replicas)
+	}
+	item, deleted := lookup(id, index, key, stores...)
+
+	responses := 1
+	successors, _ := c.GetSuccessorList(ctx)
+	for i, s := range successors {
+		if responses >= required || i >= c.cfg.ReplicationFactor-1 || s.ID() == c.ID() {
+			break
+		}
+
+		replica, tombstone, err := s.QueryReplica(ctx, index, query, key)
+		if err == nil {
+			responses++
+			// The item held by the node itself wins the ties
+			if replica.Key != "" && (item.Key == "" || replica.Version > item.Version) {
+				item = replica
+			}
+			deleted = max(deleted, tombstone)
+		} else if errors.Is(err, errs.NotFoundError) {
+			responses++
+		} else {
+			log.Printf("queryConsistent: failed to query the replica at %s: %v\n", s.ID(), err)
+		}
+	}
+
+	if responses < required {
+		return node.InsertItem{}, 0, errs.InsufficientReplicasError
+	}
+
+	return item, deleted, nil
+}
+
+// repair applies the deletion to the copies of the item held by the node and its replicas.
+func (c *Chord) repair(ctx context.Context, id util.ID, item node.DeleteItem) error {
+	_, err := c.bm.Delete(id, item.Index, item.Key, item.Version)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.replicas.Delete(id, item.Index, item.Key, item.Version)
+	if err != nil {
+		return err
+	}
+
+	c.replicateTo(ctx, func(ctx context.Context, s node.Node) error {
+		return s.DeleteReplica(ctx, item)
+	})
+	return nil
+}
+
+// lookup returns the latest copy of the item with the index and the key held by the stores, along with the version
+// of its latest tombstone. Leaves the item empty when none of the stores hold it.
+func lookup(id util.ID, index string, key string, stores ...store.Store) (node.InsertItem, hlc.Timestamp) {
+	var item node.InsertItem
+	var deleted hlc.Timestamp
+	for _, s := range stores {
+		if it, ok := s.Get(id, index, key); ok && (item.Key == "" || it.Version > item.Version) {
+			item = it
+		}
+		if version, ok := s.Tombstone(id, index, key); ok {
+			deleted = max(deleted, version)
+		}
+	}
+
+	return item, deleted
+}
+
+// QueryReplica queries the replicas held by the node on behalf of its predecessors. Given a key, it looks up the item
+// of the key instead, along with the version of its tombstone, failing with errs.NotFoundError when neither is held.
+func (c *Chord) QueryReplica(_ context.Context, index string, query string, key string) (node.InsertItem, hlc.Timestamp, error) {
+	id := c.cfg.Space.Hash(index)
+	if key != "" {
+		// The replica might have been promoted already
+		item, deleted := lookup(id, index, key, c.replicas, c.bm)
+		if item.Key == "" && deleted.IsZero() {
+			return node.InsertItem{}, 0, errs.NotFoundError
+		}
+		return item, deleted, nil
+	}
+
+	item, ok := c.replicas.Query(id, index, query)
+	if !ok {
+		// The replica might have been promoted already
+		item, ok = c.bm.Query(id, index, query)
+	}
+	if !ok {
+		return node.InsertItem{}, 0, errs.NotFoundError
+	}
+
+	return item, 0, nil
+}
+
+// getLocal returns the item of the key held by the node, or by the replicas while the predecessor is down,
+// unless deleted by a later version.
+func (c *Chord) getLocal(id util.ID, index string, key string) (node.InsertItem, error) {
+	stores := []store.Store{c.bm}
+	if c.predecessor == nil {
+		// The predecessor is down, serve from the replicas until they get promoted
+		stores = append(stores, c.replicas)
+	}
+
+	item, deleted := lookup(id, index, key, stores...)
+	if item.Key == "" || item.Version <= deleted {
+		return node.InsertItem{}, errs.NotFoundError
+	}
+
+	return item, nil
+}
+
+func (c *Chord) queryLocal(id util.ID, index string, query string) (node.InsertItem, error) {
+	item, ok := c.bm.Query(id, index, query)
+	if !ok && c.predecessor == nil {
+		// The predecessor is down, serve from the replicas until they get promoted
+		item, ok = c.replicas.Query(id, index, query)
+	}
+	if !ok {
+		return node.InsertItem{}, errs.NotFoundError
+	}
+
+	return item, nil
+}
+
+func (c *Chord) Search(ctx context.Context, index string, query string, cursor string, limit int) (node.SearchResult, error) {
+	id := c.cfg.Space.Hash(index)
+	if c.predecessor != nil && c.cfg.Space.Between(id, c.predecessor.ID(), c.ID()) {
+		return c.searchLocal(id, index, query, cursor, limit)
+	}
+
+	successor, err := c.findSuccessor(ctx, id)
+	if err != nil {
+		return node.SearchResult{}, err
+	}
+
+	if successor.ID() == c.ID() {
+		return c.searchLocal(id, index, query, cursor, limit)
+	}
+
+	return successor.Search(ctx, index, query, cursor, limit)
+}
+
+func (c *Chord) Rank(ctx context.Context, index string, search node.RankedSearch) ([]node.RankedItem, error) {
+	id := c.cfg.Space.Hash(index)
+	if c.predecessor != nil && c.cfg.Space.Between(id, c.predecessor.ID(), c.ID()) {
+		return c.rankLocal(id, index, search), nil
+	}
+
+	successor, err := c.findSuccessor(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if successor.ID() == c.ID() {
+		return c.rankLocal(id, index, search), nil
+	}
+
+	return successor.Rank(ctx, index, search)
+}
+
+func (c *Chord) Cardinality(ctx context.Context, index string) (int, error) {
+	id := c.cfg.Space.Hash(index)
+	if c.predecessor != nil && c.cfg.Space.Between(id, c.predecessor.ID(), c.ID()) {
+		return c.bm.Cardinality(id, index), nil
+	}
+
+	successor, err := c.findSuccessor(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if successor.ID() == c.ID() {
+		return c.bm.Cardinality(id, index), nil
+	}
+
+	return successor.Cardinality(ctx, index)
+}
+
+// CollectionStats estimates the number of keys in the ring and their average number of words, for ranking the search results.
+// The keys held by the node are extrapolated by the share of the ring the node owns.
+func (c *Chord) CollectionStats() (keys float64, avgLength float64) {
+	stats := c.bm.Stats()
+	if stats.Items == 0 {
+		return 0, 0
+	}
+
+	share := 1.0
+	if pred := c.predecessor; pred != nil {
+		share = c.cfg.Space.Share(pred.ID(), c.ID())
+	}
+
+	return stats.Keys / share, float64(stats.Items) / stats.Keys
+}
+
+// searchLocal pages through the matching items by key, so that any replica can continue the search of another.
+func (c *Chord) searchLocal(id util.ID, index string, query string, cursor string, limit int) (node.SearchResult, error) {
+	after, err := DecodeCursor(cursor)
+	if err != nil {
+		return node.SearchResult{}, err
+	}
+	if limit < 1 {
+		limit = 1
+	}
+
+	// Fetch an extra item to tell whether there is a next page
+	items := c.bm.Search(id, index, query, after, limit+1)
+	if len(items) == 0 && c.predecessor == nil {
+		// The predecessor is down, serve from the replicas until they get promoted
+		items = c.replicas.Search(id, index, query, after, limit+1)
+	}
+
+	result := node.SearchResult{Items: items}
+	if len(items) > limit {
+		result.Items = items[:limit]
+		result.Cursor = EncodeCursor(items[limit-1].Key)
+	}
+
+	return result, nil
+}
+
+// rankLocal scores the matches of the search among the items of the index and keeps the top ones after the boundary.
+func (c *Chord) rankLocal(id util.ID, index string, search node.RankedSearch) []node.RankedItem {
+	r := query.NewRanker(search.Words, search.DF, search.Keys, search.AvgLength, search.Distance)
+	score := func(tokens []string) (float64, bool, bool) {
+		if !search.Expr.Match(tokens) {
+			return 0, false, false
+		}
+		return r.Score(tokens), search.Exact != nil && !search.Exact.Match(tokens), true
+	}
+
+	limit := max(search.Limit, 1)
+	ranked := c.bm.Rank(id, index, search.Query, score, search.After, limit)
+	if len(ranked) == 0 && c.predecessor == nil {
+		// The predecessor is down, serve from the replicas until they get promoted
+		ranked = c.replicas.Rank(id, index, search.Query, score, search.After, limit)
+	}
+
+	return ranked
+}
+
+// EncodeCursor encodes a cursor continuing a search after the key.
+func EncodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// DecodeCursor returns the key a search continues after. An empty cursor decodes to an empty key, which starts from the first item.
+func DecodeCursor(cursor string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errs.InvalidCursorError
+	}
+
+	return string(key), nil
+}
+
+func (c *Chord) insertLocal(ctx context.Context, consistency node.Consistency, items []node.InsertItem) error {
+	stamped := make([]node.InsertItem, 0, len(items))
+	for _, item := range items {
+		item.Version = c.stamp(item.Version)
+		stamped = append(stamped, item)
+	}
+	items = stamped
+
+	for _, item := range items {
+		itemHash := c.cfg.Space.Hash(item.Index)
+		err := write(c.bm, itemHash, item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.replicate(ctx, consistency, items)
+}
+
+// write stores the item in the store as told by the write mode of the item.
+func write(s store.Store, bucketId util.ID, item node.InsertItem) error {
+	switch item.Mode {
+	case node.Upsert:
+		_, err := s.Put(bucketId, item)
+		return err
+	case node.Update:
+		existed, err := s.Replace(bucketId, item)
+		if err == nil && !existed {
+			return errs.NotFoundError
+		}
+		return err
+	default:
+		return s.Add(bucketId, item)
+	}
+}
+
+// replicate pushes the items to the next ReplicationFactor-1 successors.
+func (c *Chord) replicate(ctx context.Context, consistency node.Consistency, items []node.InsertItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	return c.pushToReplicas(ctx, consistency, func(ctx context.Context, s node.Node) error {
+		return s.Replicate(ctx, items...)
+	})
+}
+
+// replicateDeletes pushes the deletions of the items to the next ReplicationFactor-1 successors.
+func (c *Chord) replicateDeletes(ctx context.Context, consistency node.Consistency, items []node.DeleteItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	return c.pushToReplicas(ctx, consistency, func(ctx context.Context, s node.Node) error {
+		return s.DeleteReplica(ctx, items...)
+	})
+}
+
+// pushToReplicas pushes a write to the next ReplicationFactor-1 successors.
+// Fails when fewer replicas than required by the consistency level acknowledge the write.
+// With consistency level ONE, the write gets replicated in the background.
+func (c *Chord) pushToReplicas(ctx context.Context, consistency node.Consistency, push func(ctx context.Context, s node.Node) error) error {
+	if c.cfg.ReplicationFactor <= 1 {
+		return nil
+	}
+
+	required := c.cfg.requiredReplicas(consistency)
+	if required <= 1 {
+		go c.replicateTo(context.WithoutCancel(ctx), push)
+		return nil
+	}
+
+	// The node itself holds the first copy
+	if c.replicateTo(ctx, push)+1 < required {
+		return errs.InsufficientReplicasError
+	}
+
+	return nil
+}
+
+// replicateTo pushes the write to the replicas and returns the number of acknowledgements.
+func (c *Chord) replicateTo(ctx context.Context, push func(ctx context.Context, s node.Node) error) int {
+	acks := 0
+
+	successors, _ := c.GetSuccessorList(ctx)
+	for i, s := range successors {
+		if i >= c.cfg.ReplicationFactor-1 || s.ID() == c.ID() {
+			break
+		}
+
+		err := push(ctx, s)
+		if err != nil {
+			log.Printf("replicate: failed to replicate to %s: %v\n", s.ID(), err)
+			continue
+		}
+		acks++
+	}
+
+	return acks
+}
+
+// Replicate stores the items as replicas on behalf of one of the predecessors. The owner applied the writes already,
+// so the replicas take them regardless of their write modes, keeping the later versions of the items held already.
+func (c *Chord) Replicate(_ context.Context, items ...node.InsertItem) error {
+	for _, item := range items {
+		c.clock.Update(item.Version)
+		_, err := c.replicas.Put(c.cfg.Space.Hash(item.Index), item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DropReplicas drops the replicas and the tombstones within the range (lo, hi], held on behalf of a predecessor
+// whose replica set the node left.
+func (c *Chord) DropReplicas(_ context.Context, lo util.ID, hi util.ID) error {
+	dropped, deletes, err := c.replicas.GetAndDeleteRange(lo, hi)
+	if err != nil {
+		return err
+	}
+
+	if len(dropped) > 0 || len(deletes) > 0 {
+		log.Printf("DropReplicas: dropped %d replicas and %d tombstones in range (%s, %s]\n", len(dropped), len(deletes), lo, hi)
+	}
+	return nil
+}
+
+// repairReplicas keeps up the replication factor as the successor list changes. The successors joining the replica set
+// of the node get the items and the tombstones of its range, and the ones leaving it drop their replicas of the range.
+// The successors failing to take the replicas are left out of the set, to be repaired again on the next run.
+func (c *Chord) repairReplicas(ctx context.Context) {
+	predecessor := c.predecessor
+	if predecessor == nil {
+		// The range of the node is unknown until a predecessor notifies it
+		return
+	}
+
+	current := make([]node.Node, 0, c.cfg.ReplicationFactor-1)
+	successors, _ := c.GetSuccessorList(ctx)
+	for i, s := range successors {
+		if i >= c.cfg.ReplicationFactor-1 || s.ID() == c.ID() {
+			break
+		}
+		current = append(current, s)
+	}
+
+	contains := func(nodes []node.Node, n node.Node) bool {
+		return slices.ContainsFunc(nodes, func(it node.Node) bool { return it.ID() == n.ID() })
+	}
+
+	for _, s := range c.replicaSet {
+		if contains(current, s) {
+			continue
+		}
+
+		err := s.DropReplicas(ctx, predecessor.ID(), c.ID())
+		if err != nil {
+			log.Printf("repairReplicas: failed to drop the replicas at %s: %v\n", s.ID(), err)
+		}
+	}
+
+	repaired := make([]node.Node, 0, len(current))
+	for _, s := range current {
+		if !contains(c.replicaSet, s) {
+			err := c.replicateRange(ctx, s, predecessor.ID(), c.ID())
+			if err != nil {
+				log.Printf("repairReplicas: failed to replicate to %s: %v\n", s.ID(), err)
+				continue
+			}
+		}
+		repaired = append(repaired, s)
+	}
+
+	c.replicaSet = repaired
+}
+
+// replicateRange streams the items and the tombstones of the node within the range (lo, hi] to the successor
+// as replicas, in chunks of TransferChunkSize.
+func (c *Chord) replicateRange(ctx context.Context, s node.Node, lo util.ID, hi util.ID) error {
+	cursor := store.Cursor{}
+	for {
+		items, deletes, next, done := c.bm.Scan(lo, hi, cursor, c.cfg.TransferChunkSize)
+		if len(items) > 0 {
+			err := s.Replicate(ctx, items...)
+			if err != nil {
+				return err
+			}
+		}
+		if len(deletes) > 0 {
+			err := s.DeleteReplica(ctx, deletes...)
+			if err != nil {
+				return err
+			}
+		}
+
+		if done {
+			return nil
+		}
+		cursor = next
+
+		err := c.pause(ctx)
+		if err != nil {
+			return err
+		}
+	}
 }
 
-func (c *Chord) SetSuccessor(_ context.Context, successor node.Node) error {
-	c.successorLock.Lock()
-	defer c.successorLock.Unlock()
+// promoteReplicas moves the replicas within the range (lo, hi] to the node's own buckets along with their tombstones,
+// and replicates them further to keep up the replication factor.
+func (c *Chord) promoteReplicas(ctx context.Context, lo util.ID, hi util.ID) error {
+	promoted, deletes, err := c.replicas.GetAndDeleteRange(lo, hi)
+	if err != nil {
+		return err
+	}
 
-	if successor.ID() == c.ID() {
-		c.successor = c
-		log.Println("SetSuccessor: successor set to myself")
-	} else {
-		c.successor = successor
-		log.Println("SetSuccessor: successor set to", c.successor.ID())
+	if len(promoted) == 0 && len(deletes) == 0 {
+		return nil
 	}
 
-	return nil
+	log.Printf("promoteReplicas: promoting %d replicas and %d tombstones in range (%s, %s]\n", len(promoted), len(deletes), lo, hi)
+	return c.takeOver(ctx, promoted, deletes)
 }
 
-func (c *Chord) SetPredecessor(_ context.Context, predecessor node.Node) error {
-	c.predecessorLock.Lock()
-	defer c.predecessorLock.Unlock()
-
-	if c.ID() == predecessor.ID() {
-		if c.predecessor != nil {
-			log.Printf("SetPredecessor: setting the predecessor from %d to <nil>\n", c.predecessor.ID())
-			c.predecessor = nil
+// takeOver stores the items and the tombstones as the node's own and replicates them. The later versions of the items
+// held already and the tombstones of the items deleted already win over the stale copies, and the other way around.
+func (c *Chord) takeOver(ctx context.Context, items []node.InsertItem, deletes []node.DeleteItem) error {
+	for _, item := range items {
+		c.clock.Update(item.Version)
+		_, err := c.bm.Put(c.cfg.Space.Hash(item.Index), item)
+		if err != nil {
+			return err
 		}
+	}
 
-		return nil
+	err := c.tombstone(c.bm, deletes)
+	if err != nil {
+		return err
 	}
 
-	if c.predecessor != nil {
-		log.Printf("SetPredecessor: setting the predecessor from %d to %d\n", c.predecessor.ID(), predecessor.ID())
-	} else {
-		log.Printf("SetPredecessor: setting the predecessor from <nil> to %d\n", predecessor.ID())
+	err = c.replicate(ctx, node.One, items)
+	if err != nil {
+		return err
 	}
 
-	c.predecessor = predecessor
-	return nil
+	return c.replicateDeletes(ctx, node.One, deletes)
 }
 
-func (c *Chord) closestPrecedingNode(id uint64) node.Node {
-	for i := util.M - 1; i >= 0; i-- {
-		if c.finger[i] != nil && util.Between(c.finger[i].ID(), c.ID(), id) {
-			return c.finger[i]
+// tombstone applies the deletions to the store by last-writer-wins, leaving their tombstones in place of the items.
+func (c *Chord) tombstone(s store.Store, deletes []node.DeleteItem) error {
+	for _, item := range deletes {
+		c.clock.Update(item.Version)
+		_, err := s.Delete(c.cfg.Space.Hash(item.Index), item.Index, item.Key, item.Version)
+		if err != nil {
+			return err
 		}
 	}
 
-	return c
+	return nil
 }
 
-// InsertBatch locally stores the items having the Index hash within the range of node's and its predecessor's ID.
-// Forwards the rest of the items to the correct successor.
-func (c *Chord) InsertBatch(ctx context.Context, items ...node.InsertItem) error {
-	if len(items) == 0 {
+// AcceptHandoff pulls the items and the tombstones of the handoff and confirms it, upon which the offering node
+// lets go of them. The replicas of a handoff are kept as replicas, and the rest of the items are taken over.
+// When the transfer or the confirmation fails, the offering node offers the items again later on.
+func (c *Chord) AcceptHandoff(ctx context.Context, from node.Node, handoff node.Handoff) error {
+	if handoff.Size == 0 {
 		return nil
 	}
 
-	itemsById := map[uint64][]node.InsertItem{}
-	for _, item := range items {
-		id := util.Hash(item.Index)
-		if _, ok := itemsById[id]; !ok {
-			itemsById[id] = make([]node.InsertItem, 0)
-		}
-
-		itemsById[id] = append(itemsById[id], item)
-	}
-
-	for id, its := range itemsById {
-		if c.predecessor != nil && util.Between(id, c.predecessor.ID(), c.ID()) {
-			err := c.insertLocal(ctx, its)
-			if err != nil {
-				return err
-			}
-		} else {
-			successor, err := c.FindSuccessor(ctx, id)
-			if err != nil {
-				return err
-			}
-
-			if successor.ID() == c.ID() {
-				err = c.insertLocal(ctx, its)
-				if err != nil {
-					return err
-				}
-				continue
-			}
+	defer c.transfers.Delete(handoff.ID)
+	err := from.Transfer(ctx, handoff.ID, func(chunk node.TransferChunk) error {
+		c.transfers.Store(handoff.ID, node.TransferChunk{Sent: chunk.Sent, Total: chunk.Total})
+		log.Printf("AcceptHandoff: received %d/%d items of handoff %s\n", chunk.Sent, chunk.Total, handoff.ID)
 
-			err = successor.InsertBatch(ctx, its...)
+		if handoff.Replicas {
+			err := c.Replicate(ctx, chunk.Items...)
 			if err != nil {
 				return err
 			}
+			return c.tombstone(c.replicas, chunk.Deletes)
 		}
+		return c.takeOver(ctx, chunk.Items, chunk.Deletes)
+	})
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return from.ConfirmHandoff(ctx, handoff.ID)
 }
 
-func (c *Chord) Query(ctx context.Context, index string, query string) (string, error) {
-	id := util.Hash(index)
-	if c.predecessor != nil && util.Between(id, c.predecessor.ID(), c.ID()) {
-		return c.queryLocal(id, index, query)
-	} else {
-		successor, err := c.FindSuccessor(ctx, id)
+// Transfer streams the items and the tombstones of the range of the pending handoff straight from the store
+// in chunks of TransferChunkSize, pausing TransferInterval between the chunks. The items deleted since the offer
+// go as tombstones, and the items written since then go with their later versions. Records what was sent,
+// to be let go of once confirmed.
+func (c *Chord) Transfer(ctx context.Context, id string, send func(chunk node.TransferChunk) error) error {
+	c.handoffLock.Lock()
+	pending, ok := c.handoffs[id]
+	c.handoffLock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown handoff %s", id)
+	}
+
+	s := pending.store(c)
+	total := s.Count(pending.lo, pending.hi)
+	sent := 0
+	cursor := store.Cursor{}
+	for {
+		items, deletes, next, done := s.Scan(pending.lo, pending.hi, cursor, c.cfg.TransferChunkSize)
+		sent += len(items) + len(deletes)
+
+		err := send(node.TransferChunk{Items: items, Deletes: deletes, Sent: sent, Total: max(total, sent)})
 		if err != nil {
-			return "", err
+			return err
 		}
 
-		if successor.ID() == c.ID() {
-			return c.queryLocal(id, index, query)
+		c.handoffLock.Lock()
+		pending, ok = c.handoffs[id]
+		if ok {
+			pending.items = append(pending.items, items...)
+			pending.deletes = append(pending.deletes, deletes...)
+			c.handoffs[id] = pending
+		}
+		c.handoffLock.Unlock()
+
+		if !ok {
+			return fmt.Errorf("handoff %s superseded", id)
+		}
+		if done {
+			return nil
 		}
+		cursor = next
 
-		value, err := successor.Query(ctx, index, query)
+		err = c.pause(ctx)
 		if err != nil {
-			return "", err
+			return err
 		}
-		return value, nil
 	}
 }
 
-func (c *Chord) queryLocal(id uint64, index string, query string) (string, error) {
-	value, ok := c.bm.Query(id, index, query)
-	if !ok {
-		return "", errs.NotFoundError
+// pause waits TransferInterval between the chunks of a transfer.
+func (c *Chord) pause(ctx context.Context) error {
+	if c.cfg.TransferInterval <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(c.cfg.TransferInterval):
+		return nil
 	}
+}
 
-	return value, nil
+// pendingHandoff is a handoff offered by the node and not confirmed yet.
+type pendingHandoff struct {
+	// lo and hi bound the range (lo, hi] of the buckets handed off. Equal bounds span the whole ring.
+	lo util.ID
+	hi util.ID
+	// items and deletes are the items and the tombstones transferred so far.
+	items   []node.InsertItem
+	deletes []node.DeleteItem
+	// replicas tells that the items are the replicas held by the node, handed over to the successor of a leaving node.
+	replicas bool
+	// replicate keeps the items as replicas once confirmed, when the node is among the replicas of the receiver.
+	replicate bool
+	// receiver is the node the handoff is offered to, when replicate.
+	receiver node.Node
 }
 
-func (c *Chord) insertLocal(_ context.Context, items []node.InsertItem) error {
-	for _, item := range items {
-		itemHash := util.Hash(item.Index)
-		err := c.bm.Add(itemHash, item)
+// store returns the store of the node holding the items of the handoff.
+func (h pendingHandoff) store(c *Chord) store.Store {
+	if h.replicas {
+		return c.replicas
+	}
+	return c.bm
+}
+
+// offerHandoff records the range of the handoff as pending a confirmation from the receiver, which is the predecessor
+// when replicate and the successor of a leaving node otherwise. Offers nothing when the range is empty.
+// A new offer supersedes the earlier ones, since it covers all the items not confirmed yet.
+func (c *Chord) offerHandoff(pending pendingHandoff) node.Handoff {
+	size := pending.store(c).Count(pending.lo, pending.hi)
+	if size == 0 {
+		return node.Handoff{}
+	}
+
+	c.handoffLock.Lock()
+	defer c.handoffLock.Unlock()
+
+	c.handoffSeq++
+	handoff := node.Handoff{ID: fmt.Sprintf("%s-%d", c.ID(), c.handoffSeq), Size: size, Replicas: pending.replicas}
+	c.handoffs = map[string]pendingHandoff{handoff.ID: pending}
+
+	return handoff
+}
+
+// ConfirmHandoff deletes the items and the tombstones of the handoff once the receiver stored them. The items written
+// and deleted since the offer are of later versions and stay, to be offered again. Unknown handoffs are either
+// confirmed already or superseded by a later offer, and are ignored.
+func (c *Chord) ConfirmHandoff(ctx context.Context, id string) error {
+	c.handoffLock.Lock()
+	pending, ok := c.handoffs[id]
+	delete(c.handoffs, id)
+	c.handoffLock.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	log.Printf("ConfirmHandoff: handing off %d items and %d tombstones\n", len(pending.items), len(pending.deletes))
+	s := pending.store(c)
+	for _, item := range pending.items {
+		_, err := s.Remove(c.cfg.Space.Hash(item.Index), item.Index, item.Key, item.Version)
+		if err != nil {
+			return err
+		}
+	}
+	for _, item := range pending.deletes {
+		_, err := s.Forget(c.cfg.Space.Hash(item.Index), item.Index, item.Key, item.Version)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if !pending.replicate || !c.replicates(ctx, pending.receiver) {
+		return nil
+	}
+
+	// Being among the replicas of the new owner, the node keeps on holding the handed over items as replicas
+	err := c.Replicate(ctx, pending.items...)
+	if err != nil {
+		return err
+	}
+
+	return c.tombstone(c.replicas, pending.deletes)
+}
+
+// replicates reports whether the node is among the successors the owner replicates its items to.
+func (c *Chord) replicates(ctx context.Context, owner node.Node) bool {
+	if c.cfg.ReplicationFactor <= 1 {
+		return false
+	}
+
+	successors, err := owner.GetSuccessorList(ctx)
+	if err != nil {
+		log.Printf("replicates: failed to fetch the successor list of %s: %v\n", owner.ID(), err)
+		return false
+	}
+
+	for i, s := range successors {
+		if i >= c.cfg.ReplicationFactor-1 || s.ID() == owner.ID() {
+			break
+		}
+		if s.ID() == c.ID() {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (c *Chord) Notify(_ context.Context, p node.Node) ([]node.InsertItem, error) {
+func (c *Chord) Notify(ctx context.Context, p node.Node) (node.Handoff, error) {
 	c.predecessorLock.Lock()
 	defer c.predecessorLock.Unlock()
 
-	if c.predecessor == nil || (util.Between(p.ID(), c.predecessor.ID(), c.ID()) && p.ID() != c.ID()) {
+	if c.predecessor == nil || (c.cfg.Space.Between(p.ID(), c.predecessor.ID(), c.ID()) && p.ID() != c.ID()) {
 		if c.predecessor != nil {
-			log.Printf("Notify: setting the predecessor from %d to %d\n", c.predecessor.ID(), p.ID())
+			log.Printf("Notify: setting the predecessor from %s to %s\n", c.predecessor.ID(), p.ID())
 		} else {
-			log.Printf("Notify: setting the predecessor from <nil> to %d\n", p.ID())
+			log.Printf("Notify: setting the predecessor from <nil> to %s\n", p.ID())
 		}
 		c.predecessor = p
 
-		items := c.bm.GetAndDeleteLessThanEqual(c.predecessor.ID(), c.ID())
-		insert := make([]node.InsertItem, 0, len(items))
-
-		for _, item := range items {
-			insert = append(insert, node.InsertItem{
-				Index: item.Index,
-				Key:   item.Key,
-				Value: item.Value,
-			})
+		// Take over the replicas of a failed predecessor that now fall within the node's range
+		err := c.promoteReplicas(ctx, c.predecessor.ID(), c.ID())
+		if err != nil {
+			return node.Handoff{}, err
 		}
+	}
 
-		return insert, nil
+	if c.predecessor.ID() != p.ID() {
+		return node.Handoff{}, nil
 	}
 
-	return nil, nil
+	// Offer the items and the tombstones outside the node's range on every notification until the predecessor confirms them
+	return c.offerHandoff(pendingHandoff{lo: c.ID(), hi: p.ID(), replicate: true, receiver: p}), nil
 }
 
 func (c *Chord) GetPredecessor(_ context.Context) (node.Node, error) {
@@ -265,36 +1652,63 @@ func (c *Chord) Join(ctx context.Context, n node.Node) error {
 		return nil
 	}
 
+	hs, err := n.Handshake(ctx, c.handshake())
+	if err != nil {
+		return err
+	}
+
+	err = c.compatible(hs)
+	if err != nil {
+		return err
+	}
+	c.peers.Store(n.Addr(), hs)
+
 	c.predecessorLock.Lock()
 	defer c.predecessorLock.Unlock()
 
+	// The ring the node joins might still be stabilizing, so the lookup is retried as the reads and the writes do
 	c.predecessor = nil
-	reply, err := n.FindSuccessor(ctx, c.ID())
+	reply, err := c.findSuccessorVia(ctx, n, c.ID())
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	if reply.ID() == c.ID() {
-		return errors.New(fmt.Sprintf("node ID [%d] already taken", c.ID()))
+		return errors.New(fmt.Sprintf("node ID [%s] already taken", c.ID()))
 	}
 
+	c.successorLock.Lock()
 	c.successor = reply
-	insert, err := c.successor.Notify(ctx, c)
+	c.resetSuccessorList()
+	c.successorLock.Unlock()
+
+	handoff, err := c.successor.Notify(ctx, c)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	if len(insert) > 0 {
-		err = c.insertLocal(ctx, insert)
-		if err != nil {
-			return err
-		}
+	return c.AcceptHandoff(ctx, c.successor, handoff)
+}
+
+func (c *Chord) Stabilize() error {
+	successor, handoff, err := c.stabilize()
+	if err != nil {
+		return err
+	}
+
+	// The transfer is throttled, so the handoff is pulled without holding up the updates of the successor
+	err = c.AcceptHandoff(context.Background(), successor, handoff)
+	if err != nil {
+		return err
 	}
 
+	c.repairReplicas(context.Background())
 	return nil
 }
 
-func (c *Chord) Stabilize() error {
+// stabilize updates the successor and the successor list, and notifies the successor,
+// returning the successor along with the handoff it offered.
+func (c *Chord) stabilize() (node.Node, node.Handoff, error) {
 	// TODO: Maybe not when successor is myself
 	c.successorLock.Lock()
 	defer c.successorLock.Unlock()
@@ -302,34 +1716,30 @@ func (c *Chord) Stabilize() error {
 	x, err := c.successor.GetPredecessor(context.Background())
 	if err != nil {
 		if err.Error() != "no predecessor" {
-			return err
+			// The successor is unreachable, move on to the next live successor in the successor list
+			c.skipFailedSuccessor(context.Background())
+			return nil, node.Handoff{}, err
 		}
 	}
 
-	if x != nil && util.Between(x.ID(), c.ID(), c.successor.ID()) {
-		log.Printf("Stabilize: successor set from %d to %d\n", c.successor.ID(), x.ID())
+	if x != nil && c.cfg.Space.Between(x.ID(), c.ID(), c.successor.ID()) {
+		log.Printf("Stabilize: successor set from %s to %s\n", c.successor.ID(), x.ID())
 		c.successor = x
 		//log.Printf("%s [%d]: Stabilized successor %d", c.Addr(), c.ID(), c.successor.ID())
 		//n.successor.Notify(n)
 	}
 
+	var handoff node.Handoff
 	if c.successor.ID() != c.ID() {
 		//log.Printf("%s [%d]: Notified successor %d", c.Addr(), c.ID(), c.successor.ID())
-		insert, err := c.successor.Notify(context.Background(), c)
+		handoff, err = c.successor.Notify(context.Background(), c)
 		if err != nil {
 			// TODO
-			return err
-		}
-
-		if len(insert) > 0 {
-			err = c.insertLocal(context.Background(), insert)
-			if err != nil {
-				return err
-			}
+			return nil, node.Handoff{}, err
 		}
 	}
 
-	return nil
+	return c.successor, handoff, c.refreshSuccessorList(context.Background())
 }
 
 func (c *Chord) CheckPredecessor() {
@@ -343,11 +1753,25 @@ func (c *Chord) CheckPredecessor() {
 		if err != nil {
 			// If the health check fails, set the predecessor to nil
 			log.Println("health check failed, setting the predecessor to <nil>")
-			c.predecessor = nil
+			failed, bound := c.predecessor, c.predecessorBound
+			c.predecessor, c.predecessorBound = nil, nil
+
+			// Take over the replicas of the failed predecessor right away, rather than once the next one notifies the node
+			if bound != nil {
+				err = c.promoteReplicas(context.Background(), bound.ID(), failed.ID())
+				if err != nil {
+					log.Printf("CheckPredecessor: failed to promote the replicas of %s: %v\n", failed.ID(), err)
+				}
+			}
+			return
+		}
+
+		// Keep track of the range of the predecessor, bounded by its own predecessor
+		bound, err := c.predecessor.GetPredecessor(context.Background())
+		if err != nil {
+			bound = nil
 		}
-		//if err != nil {
-		//	log.Printf("%s [%d]: predecessor { %d: %s } not healthy", n.Addr, n.Id, n.Predecessor.Id, n.Predecessor.Addr)
-		//}
+		c.predecessorBound = bound
 	}
 }
 
@@ -355,20 +1779,20 @@ func (c *Chord) FixFinger(fingerNumber int) error {
 	if fingerNumber < 0 {
 		return errors.New("cannot be less than 0")
 	}
-	if fingerNumber > util.M {
-		return errors.New(fmt.Sprintf("cannot exceed %d", util.M))
+	if fingerNumber > c.cfg.Space.M {
+		return errors.New(fmt.Sprintf("cannot exceed %d", c.cfg.Space.M))
 	}
 
 	fingerIndex := fingerNumber - 1
 
-	fId := (int(c.ID()) + int(math.Pow(2, float64(fingerNumber-1)))) % int(math.Pow(2, float64(util.M)))
+	fId := c.cfg.Space.Finger(c.ID(), fingerNumber)
 
 	var err error
-	c.finger[fingerIndex], err = c.FindSuccessor(context.Background(), uint64(fId))
+	c.finger[fingerIndex], err = c.FindSuccessor(context.Background(), fId, c.route())
 	if err != nil {
 		return err
 	}
-	c.fingerIdx[fingerIndex] = uint64(fId)
+	c.fingerIdx[fingerIndex] = fId
 
 	if c.finger[fingerIndex] != nil {
 		//log.Printf("%s [%d]: Finger resolved { Index: %d, id: %d, successor: %d }", c.Addr(), c.ID(), fingerIndex, fId, c.finger[fingerIndex].ID())
@@ -377,8 +1801,12 @@ func (c *Chord) FixFinger(fingerNumber int) error {
 	return err
 }
 
+// Leave hands the node's items over to the successor and leaves the ring.
+// The items are deleted only after the successor confirms the handoff, so a failed Leave can be retried.
 func (c *Chord) Leave(ctx context.Context) error {
-	close(c.stopChan)
+	c.stopOnce.Do(func() {
+		close(c.stopChan)
+	})
 	c.wg.Wait()
 
 	hasSuccessor := c.successor.ID() != c.ID()
@@ -399,21 +1827,17 @@ func (c *Chord) Leave(ctx context.Context) error {
 	}
 
 	// Transfer key-value data to the successor
-	if hasSuccessor && c.successor.ID() != c.ID() {
-		snapshot := c.bm.Snapshot()
-		insert := make([]node.InsertItem, 0, len(snapshot))
-
-		for _, item := range snapshot {
-			insert = append(insert, node.InsertItem{
-				Index: item.Index,
-				Key:   item.Key,
-				Value: item.Value,
-			})
+	if hasSuccessor {
+		if handoff := c.offerHandoff(pendingHandoff{lo: c.ID(), hi: c.ID()}); handoff.Size > 0 {
+			err := c.successor.AcceptHandoff(ctx, c, handoff)
+			if err != nil {
+				return err
+			}
 		}
 
-		log.Printf("transferring %+v\n", insert)
-		if len(insert) > 0 {
-			err := c.successor.InsertBatch(ctx, insert...)
+		// Hand over the replicas as well, so the predecessors' items do not lose a copy
+		if handoff := c.offerHandoff(pendingHandoff{lo: c.ID(), hi: c.ID(), replicas: true}); handoff.Size > 0 {
+			err := c.successor.AcceptHandoff(ctx, c, handoff)
 			if err != nil {
 				return err
 			}
@@ -424,8 +1848,8 @@ func (c *Chord) Leave(ctx context.Context) error {
 }
 
 func (c *Chord) StartJobs() {
+	c.wg.Add(1)
 	go func() {
-		c.wg.Add(1)
 
 		t := time.NewTicker(time.Millisecond * 100)
 
@@ -446,8 +1870,8 @@ func (c *Chord) StartJobs() {
 		}
 	}()
 
+	c.wg.Add(1)
 	go func() {
-		c.wg.Add(1)
 		n := 1
 
 		t := time.NewTicker(time.Millisecond * 150)
@@ -459,7 +1883,7 @@ func (c *Chord) StartJobs() {
 				log.Println("stopping fix finger job")
 				return
 			case <-t.C:
-				if n > util.M {
+				if n > c.cfg.Space.M {
 					n = 1
 				}
 
@@ -473,8 +1897,8 @@ func (c *Chord) StartJobs() {
 		}
 	}()
 
+	c.wg.Add(1)
 	go func() {
-		c.wg.Add(1)
 		n := 1
 
 		t := time.NewTicker(time.Millisecond * 250)
@@ -486,7 +1910,7 @@ func (c *Chord) StartJobs() {
 				log.Println("stopping check predecessor job")
 				return
 			case <-t.C:
-				if n > util.M {
+				if n > c.cfg.Space.M {
 					n = 1
 				}
 
@@ -495,6 +1919,88 @@ func (c *Chord) StartJobs() {
 			}
 		}
 	}()
+
+	c.wg.Add(1)
+	go func() {
+		t := time.NewTicker(c.cfg.SnapshotInterval)
+		for {
+			select {
+			case <-c.stopChan:
+				t.Stop()
+				c.wg.Done()
+				log.Println("stopping snapshot job")
+				return
+			case <-t.C:
+				err := c.compact()
+				if err != nil {
+					log.Printf("snapshot: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	c.wg.Add(1)
+	go func() {
+		t := time.NewTicker(c.cfg.ReapInterval)
+		for {
+			select {
+			case <-c.stopChan:
+				t.Stop()
+				c.wg.Done()
+				log.Println("stopping reaper job")
+				return
+			case <-t.C:
+				err := c.reap(time.Now())
+				if err != nil {
+					log.Printf("reaper: %v\n", err)
+				}
+			}
+		}
+	}()
+}
+
+// reap evicts the items whose deadlines passed by the time from the buckets and the replicas. Every index of a key
+// holds the deadline of the key, so the nodes owning its indexes evict them on their own.
+func (c *Chord) reap(now time.Time) error {
+	evicted, err := c.bm.Expire(now)
+	if err != nil {
+		return err
+	}
+
+	replicas, err := c.replicas.Expire(now)
+	if err != nil {
+		return err
+	}
+
+	if evicted+replicas > 0 {
+		log.Printf("reap: evicted %d items and %d replicas\n", evicted, replicas)
+	}
+	return nil
+}
+
+// compact folds the write-ahead logs of the buckets and the replicas into snapshots and expires their tombstones.
+func (c *Chord) compact() error {
+	err := c.bm.Compact()
+	if err != nil {
+		return err
+	}
+
+	return c.replicas.Compact()
+}
+
+// Close compacts and closes the storage of the node. The node must not be used afterward.
+func (c *Chord) Close() error {
+	err := c.compact()
+	if err != nil {
+		return err
+	}
+
+	err = c.bm.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.replicas.Close()
 }
 
 func (c *Chord) Healthz(_ context.Context) error {
@@ -503,20 +2009,32 @@ func (c *Chord) Healthz(_ context.Context) error {
 
 func (c *Chord) Debug() string {
 	type fingerNode struct {
-		ID      uint64 `json:"id"`
-		Address string `json:"address"`
+		ID      util.ID `json:"id"`
+		Address string  `json:"address"`
+	}
+
+	type transfer struct {
+		Sent  int `json:"sent"`
+		Total int `json:"total"`
 	}
 
 	data := struct {
-		ID          uint64          `json:"id"`
-		Address     string          `json:"address"`
-		Successor   *fingerNode     `json:"successor"`
-		Predecessor *fingerNode     `json:"predecessor"`
-		FingerTable json.RawMessage `json:"finger_table"`
-		Buckets     json.RawMessage `json:"buckets"`
+		ID          util.ID             `json:"id"`
+		Address     string              `json:"address"`
+		Successor   *fingerNode         `json:"successor"`
+		Successors  []fingerNode        `json:"successors"`
+		Predecessor *fingerNode         `json:"predecessor"`
+		FingerTable json.RawMessage     `json:"finger_table"`
+		Buckets     json.RawMessage     `json:"buckets"`
+		Replicas    json.RawMessage     `json:"replicas"`
+		Transfers   map[string]transfer `json:"transfers"`
+		Stats       struct {
+			Buckets  store.Stats `json:"buckets"`
+			Replicas store.Stats `json:"replicas"`
+		} `json:"stats"`
 	}{}
 
-	fingerTable := map[uint64]fingerNode{}
+	fingerTable := map[util.ID]fingerNode{}
 
 	for i, idx := range c.fingerIdx {
 		fingerTable[idx] = fingerNode{ID: c.finger[i].ID(), Address: c.finger[i].Addr()}
@@ -532,11 +2050,60 @@ func (c *Chord) Debug() string {
 	if c.successor != nil {
 		data.Successor = &fingerNode{ID: c.successor.ID(), Address: c.successor.Addr()}
 	}
+	successors, _ := c.GetSuccessorList(context.Background())
+	for _, s := range successors {
+		data.Successors = append(data.Successors, fingerNode{ID: s.ID(), Address: s.Addr()})
+	}
 	if c.predecessor != nil {
 		data.Predecessor = &fingerNode{ID: c.predecessor.ID(), Address: c.predecessor.Addr()}
 	}
 	data.FingerTable = fingerTableJson
 	data.Buckets = c.bm.Debug()
+	data.Stats.Buckets = c.bm.Stats()
+	data.Stats.Replicas = c.replicas.Stats()
+	data.Replicas = c.replicas.Debug()
+	data.Transfers = map[string]transfer{}
+	c.transfers.Range(func(key, value any) bool {
+		progress := value.(node.TransferChunk)
+		data.Transfers[key.(string)] = transfer{Sent: progress.Sent, Total: progress.Total}
+		return true
+	})
+
+	result, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return ""
+	}
+
+	return string(result)
+}
+
+// DebugLookup resolves the successor of the key iteratively and describes the path taken.
+func (c *Chord) DebugLookup(ctx context.Context, key string) string {
+	type hop struct {
+		ID      util.ID `json:"id"`
+		Address string  `json:"address"`
+	}
+
+	data := struct {
+		Key       string  `json:"key"`
+		ID        util.ID `json:"id"`
+		Successor *hop    `json:"successor"`
+		Path      []hop   `json:"path"`
+		Hops      int     `json:"hops"`
+		Error     string  `json:"error,omitempty"`
+	}{Key: key, ID: c.cfg.Space.Hash(key)}
+
+	lookup, err := c.Lookup(ctx, data.ID)
+	if err != nil {
+		data.Error = err.Error()
+	}
+	if lookup.Successor != nil {
+		data.Successor = &hop{ID: lookup.Successor.ID(), Address: lookup.Successor.Addr()}
+	}
+	for _, n := range lookup.Path {
+		data.Path = append(data.Path, hop{ID: n.ID(), Address: n.Addr()})
+	}
+	data.Hops = lookup.Hops
 
 	result, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
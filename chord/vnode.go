@@ -0,0 +1,49 @@
+package chord
+
+import (
+	"fmt"
+	"github.com/yousuf64/chord-kv/util"
+	"log"
+	"math"
+)
+
+// VirtualNodeID returns the ID of the i-th virtual node hosted at the address.
+// The first virtual node takes the ID of the address itself, so that the host can be reached by its address alone.
+func VirtualNodeID(space util.Space, addr string, i int) util.ID {
+	if i == 0 {
+		return space.Hash(addr)
+	}
+
+	return space.Hash(fmt.Sprintf("%s#%d", addr, i))
+}
+
+// VirtualNodeCount weights the number of virtual nodes per host by the capacity of the host.
+// A host with a capacity of 2 hosts twice as many virtual nodes, hence owns about twice as many keys.
+func VirtualNodeCount(perHost int, capacity float64) int {
+	count := int(math.Round(float64(perHost) * capacity))
+	if count < 1 {
+		return 1
+	}
+
+	return count
+}
+
+// NewVirtualNodes creates count virtual nodes hosted at the address, each having its own finger table and buckets.
+// Virtual nodes colliding with the ID of another virtual node of the host are skipped.
+func NewVirtualNodes(addr string, count int, cfg Config) []*Chord {
+	vnodes := make([]*Chord, 0, count)
+	ids := map[util.ID]struct{}{}
+
+	for i := 0; i < count; i++ {
+		id := VirtualNodeID(cfg.Space, addr, i)
+		if _, ok := ids[id]; ok {
+			log.Printf("NewVirtualNodes: skipping virtual node %d, ID [%s] already taken\n", i, id)
+			continue
+		}
+
+		ids[id] = struct{}{}
+		vnodes = append(vnodes, newChord(addr, id, cfg))
+	}
+
+	return vnodes
+}
@@ -7,3 +7,12 @@ import (
 
 var NotFoundError = errors.New("not found")
 var AlreadyExistsError = fmt.Errorf("item already exists")
+var VersionMismatchError = errors.New("item version does not match")
+var InsufficientReplicasError = errors.New("insufficient replicas to satisfy the consistency level")
+var IncompatiblePeerError = errors.New("incompatible peer")
+var LookupLoopError = errors.New("lookup revisited a node")
+var LookupHopLimitError = errors.New("lookup exceeded the hop limit")
+var InvalidCursorError = errors.New("invalid cursor")
+var InvalidQueryError = errors.New("invalid query")
+var EmptyKeyError = errors.New("key has no searchable words")
+var FuzzyDisabledError = errors.New("fuzzy search is disabled")
@@ -0,0 +1,95 @@
+package hlc
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// logicalBits is the number of the lower bits of a Timestamp holding the logical counter.
+const logicalBits = 16
+
+// Timestamp is a reading of a hybrid logical clock. It packs the wall time in milliseconds into the upper 48 bits
+// and a logical counter into the lower 16 bits, so that the timestamps order by comparing them as integers.
+// The zero Timestamp precedes every reading of a clock.
+type Timestamp uint64
+
+// New returns the timestamp of the wall time with the logical counter.
+func New(wall time.Time, logical uint16) Timestamp {
+	return Timestamp(uint64(wall.UnixMilli())<<logicalBits | uint64(logical))
+}
+
+// WallTime returns the wall time of the timestamp in millisecond precision.
+func (t Timestamp) WallTime() time.Time {
+	return time.UnixMilli(int64(t >> logicalBits))
+}
+
+// Logical returns the logical counter of the timestamp, which orders the timestamps of the same wall time.
+func (t Timestamp) Logical() uint16 {
+	return uint16(t)
+}
+
+func (t Timestamp) IsZero() bool {
+	return t == 0
+}
+
+// String formats the timestamp as a decimal integer, as parsed by Parse.
+func (t Timestamp) String() string {
+	return strconv.FormatUint(uint64(t), 10)
+}
+
+func Parse(s string) (Timestamp, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return Timestamp(v), nil
+}
+
+// MaxOffset is how far ahead of the wall time of a node the clocks of the other nodes are expected to run at most.
+const MaxOffset = 500 * time.Millisecond
+
+// Clock issues the timestamps of the writes of a node. The timestamps follow the wall time of the node
+// while staying ahead of every timestamp the node has issued or observed, so that the causally related writes
+// are ordered even when the wall clocks of the nodes drift apart.
+type Clock struct {
+	lock sync.Mutex
+	last Timestamp
+	now  func() time.Time
+}
+
+func NewClock() *Clock {
+	return &Clock{now: time.Now}
+}
+
+// Now returns a timestamp after every timestamp issued or observed by the clock.
+func (c *Clock) Now() Timestamp {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	wall := New(c.now(), 0)
+	if wall > c.last {
+		c.last = wall
+	} else {
+		// An overflowing logical counter carries over into the wall time
+		c.last++
+	}
+
+	return c.last
+}
+
+// Update observes a timestamp issued by another clock, so that the timestamps issued afterward come after it.
+func (c *Clock) Update(t Timestamp) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.last = max(c.last, t)
+}
+
+// Plausible reports whether the timestamp could have been issued by a clock within MaxOffset of the clock,
+// telling the timestamps made up by the clients apart before they get observed.
+func (c *Clock) Plausible(t Timestamp) bool {
+	return t <= New(c.now().Add(MaxOffset), math.MaxUint16)
+}
@@ -0,0 +1,69 @@
+package hlc
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func Test_Timestamp(t *testing.T) {
+	wall := time.UnixMilli(1700000000123)
+	ts := New(wall, 7)
+	if !ts.WallTime().Equal(wall) || ts.Logical() != 7 {
+		t.Fatalf("expected %v/7, got %v/%d", wall, ts.WallTime(), ts.Logical())
+	}
+	if New(wall, 0xffff) >= New(wall.Add(time.Millisecond), 0) {
+		t.Fatalf("expected the wall time to order before the logical counter")
+	}
+
+	parsed, err := Parse(ts.String())
+	if err != nil || parsed != ts {
+		t.Fatalf("expected %s parsed back, got %s: %v", ts, parsed, err)
+	}
+	if _, err = Parse("W/1"); err == nil {
+		t.Fatalf("expected a malformed timestamp to fail")
+	}
+}
+
+func Test_Clock(t *testing.T) {
+	wall := time.UnixMilli(1700000000000)
+	c := &Clock{now: func() time.Time { return wall }}
+
+	// The readings of the same wall time advance the logical counter
+	first := c.Now()
+	second := c.Now()
+	if first != New(wall, 0) || second != New(wall, 1) {
+		t.Fatalf("expected consecutive readings, got %s and %s", first, second)
+	}
+
+	// The readings stay ahead of the timestamps observed from a clock running ahead
+	ahead := New(wall.Add(time.Second), 3)
+	c.Update(ahead)
+	if now := c.Now(); now != ahead+1 {
+		t.Fatalf("expected the reading after %s, got %s", ahead, now)
+	}
+
+	// An earlier timestamp leaves the clock as it is
+	c.Update(first)
+	if now := c.Now(); now != ahead+2 {
+		t.Fatalf("expected the reading after %s, got %s", ahead+1, now)
+	}
+
+	// The wall time takes over once it passes the observed timestamps
+	wall = wall.Add(2 * time.Second)
+	if now := c.Now(); now != New(wall, 0) {
+		t.Fatalf("expected the wall time, got %s", now)
+	}
+}
+
+func Test_Plausible(t *testing.T) {
+	wall := time.UnixMilli(1700000000000)
+	c := &Clock{now: func() time.Time { return wall }}
+
+	if !c.Plausible(New(wall.Add(MaxOffset), 0xffff)) {
+		t.Fatalf("expected a timestamp within the offset to be plausible")
+	}
+	if c.Plausible(New(wall.Add(MaxOffset+time.Millisecond), 0)) || c.Plausible(Timestamp(math.MaxUint64)) {
+		t.Fatalf("expected a timestamp beyond the offset not to be plausible")
+	}
+}
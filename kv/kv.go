@@ -2,62 +2,518 @@ package kv
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/yousuf64/chord-kv/analysis"
 	"github.com/yousuf64/chord-kv/chord"
+	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/hlc"
 	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/query"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 type KV interface {
-	Insert(ctx context.Context, key string, value string) error
-	Get(ctx context.Context, query string) (string, error)
+	Insert(ctx context.Context, key string, value string, ttl time.Duration, consistency node.Consistency) (hlc.Timestamp, error)
+	Put(ctx context.Context, key string, value string, ttl time.Duration, consistency node.Consistency) (hlc.Timestamp, error)
+	Update(ctx context.Context, key string, value string, ttl time.Duration, consistency node.Consistency) (hlc.Timestamp, error)
+	CompareAndSwap(ctx context.Context, key string, expected hlc.Timestamp, value string, ttl time.Duration, consistency node.Consistency) (hlc.Timestamp, error)
+	Delete(ctx context.Context, key string, consistency node.Consistency) error
+	Get(ctx context.Context, query string, consistency node.Consistency, fuzzy bool) (Hit, error)
+	Search(ctx context.Context, query string, cursor string, limit int, fuzzy bool) (SearchResult, error)
 
 	// DEBUG
 	Debug() string
+	DebugLookup(ctx context.Context, key string) string
 }
 
+// CardinalityTTL is how long the cardinalities published by the index nodes are cached for planning the queries.
+const CardinalityTTL = 5 * time.Second
+
+// CardinalityCacheSize is the number of terms whose cardinalities are cached at most.
+const CardinalityCacheSize = 10000
+
+// authorityConcurrency is the number of the hits of a search page whose first indexes are read at a time.
+const authorityConcurrency = 8
+
+// PrefixLength is the number of leading letters of the tokens indexed for the prefix searches,
+// which is the shortest prefix a search can be anchored by.
+const PrefixLength = 3
+
+// FuzzyDistance is the number of edits a word of a fuzzy query is allowed to be off by.
+// The deletion variants find the candidates up to two edits off, which are narrowed down to the distance.
+const FuzzyDistance = 1
+
 type DistributedKV struct {
-	c *chord.Chord
+	c         *chord.Chord
+	vnodes    []*chord.Chord
+	planner   *Planner
+	tokenizer analysis.Tokenizer
+	fuzzy     bool
+
+	collection struct {
+		lock      sync.Mutex
+		keys      float64
+		avgLength float64
+		fetched   time.Time
+	}
+}
+
+// NewDistributedKV serves the requests through the given Chord node, tokenizing the keys and the queries
+// with the tokenizer of the node. The rest of the virtual nodes hosted by the process only show up in the debug output.
+func NewDistributedKV(chord *chord.Chord, vnodes ...*chord.Chord) *DistributedKV {
+	return &DistributedKV{c: chord, vnodes: vnodes, planner: NewPlanner(chord, CardinalityTTL, CardinalityCacheSize), tokenizer: chord.Tokenizer(), fuzzy: chord.Fuzzy()}
+}
+
+// Insert inserts the KV pair to the correct node, failing with errs.AlreadyExistsError when the key is stored already.
+// When having multiple tokens in the key, it indexes by each distinct token and stores in the correct nodes to facilitate part querying.
+// It indexes by the leading PrefixLength letters of the tokens for the prefix searches as well, and when fuzzy,
+// by the deletion variants of the tokens. Every index stores the pair with the same version, which it returns.
+// A positive ttl expires the pair once it elapses, every write setting the deadline of the pair anew.
+func (d *DistributedKV) Insert(ctx context.Context, key string, value string, ttl time.Duration, consistency node.Consistency) (hlc.Timestamp, error) {
+	indexes, err := d.indexes(key)
+	if err != nil {
+		return 0, err
+	}
+
+	item := node.InsertItem{Key: key, Value: value, Mode: node.Create, Version: d.c.Clock().Now(), ExpiresAt: deadline(ttl)}
+	return item.Version, d.c.InsertBatch(ctx, consistency, items(indexes, item)...)
+}
+
+// Put inserts the KV pair as Insert does, replacing the value of the key when stored already.
+// The deadline of the key is set anew by the ttl, so a put without a ttl makes the key permanent.
+func (d *DistributedKV) Put(ctx context.Context, key string, value string, ttl time.Duration, consistency node.Consistency) (hlc.Timestamp, error) {
+	indexes, err := d.indexes(key)
+	if err != nil {
+		return 0, err
+	}
+
+	item := node.InsertItem{Key: key, Value: value, Mode: node.Upsert, Version: d.c.Clock().Now(), ExpiresAt: deadline(ttl)}
+	return item.Version, d.c.InsertBatch(ctx, consistency, items(indexes, item)...)
+}
+
+// Update replaces the value of the key, failing with errs.NotFoundError when the key is not stored.
+// The index of the first token tells whether the key is stored, after which the value is put under the rest
+// of the indexes, so that they all agree on it even when some of them missed the key.
+// The deadline of the key is set anew as Put does.
+func (d *DistributedKV) Update(ctx context.Context, key string, value string, ttl time.Duration, consistency node.Consistency) (hlc.Timestamp, error) {
+	indexes, err := d.indexes(key)
+	if err != nil {
+		return 0, err
+	}
+
+	item := node.InsertItem{Key: key, Value: value, Mode: node.Update, Version: d.c.Clock().Now(), ExpiresAt: deadline(ttl)}
+	err = d.c.InsertBatch(ctx, consistency, items(indexes[:1], item)...)
+	if err != nil {
+		return 0, err
+	}
+
+	item.Mode = node.Upsert
+	return item.Version, d.c.InsertBatch(ctx, consistency, items(indexes[1:], item)...)
+}
+
+// CompareAndSwap replaces the value of the key when it is of the expected version, failing with
+// errs.VersionMismatchError otherwise and with errs.NotFoundError when the key is not stored.
+// The owner of the index of the first token swaps the value atomically, after which the value is put under the rest
+// of the indexes as Update does. Returns the new version, which follows the expected one. The expected versions
+// further than hlc.MaxOffset ahead of the clock match no item, and fail with errs.VersionMismatchError up front.
+// The deadline of the key is set anew as Put does, so a lease is renewed by swapping it with its ttl.
+//
+// Only the first index decides between the concurrent swaps. Each successful swap is of a later version than the one
+// it replaced, so the rest of the indexes settle on the latest swap by last-writer-wins whatever order the puts
+// land in. A swap failing midway through the puts leaves the rest of the indexes behind until the next write of the key,
+// so Get and Search return the versions held by the first index, which the later swaps match against.
+func (d *DistributedKV) CompareAndSwap(ctx context.Context, key string, expected hlc.Timestamp, value string, ttl time.Duration, consistency node.Consistency) (hlc.Timestamp, error) {
+	indexes, err := d.indexes(key)
+	if err != nil {
+		return 0, err
+	}
+
+	// The expected version comes from the client, so the clock observes it only once the swap matched it
+	if !d.c.Clock().Plausible(expected) {
+		return 0, fmt.Errorf("%w: version %s is ahead of the clock", errs.VersionMismatchError, expected)
+	}
+
+	item := node.InsertItem{Index: indexes[0], Key: key, Value: value, Mode: node.Update, Version: max(d.c.Clock().Now(), expected+1), ExpiresAt: deadline(ttl)}
+	err = d.c.CompareAndSwap(ctx, consistency, item, expected)
+	if err != nil {
+		return 0, err
+	}
+	d.c.Clock().Update(item.Version)
+
+	item.Mode = node.Upsert
+	return item.Version, d.c.InsertBatch(ctx, consistency, items(indexes[1:], item)...)
 }
 
-func NewDistributedKV(chord *chord.Chord) *DistributedKV {
-	return &DistributedKV{chord}
+// items returns the copies of the item stored under each of the indexes.
+func items(indexes []string, item node.InsertItem) []node.InsertItem {
+	items := make([]node.InsertItem, 0, len(indexes))
+	for _, index := range indexes {
+		item.Index = index
+		items = append(items, item)
+	}
+
+	return items
 }
 
-// Insert inserts the KV pair to the correct node.
-// When having multiple words in the key, it indexes by each word and stores in the correct nodes to facilitate part querying.
-func (d *DistributedKV) Insert(ctx context.Context, key string, value string) error {
-	key = strings.ToLower(key)
-	split := strings.Split(key, " ")
-	// TODO: Might need to ignore repeated words... also trim spaces
+// deadline returns the deadline of a pair written now with the ttl, the zero time when the ttl is not positive.
+func deadline(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(ttl)
+}
+
+// Delete deletes the KV pair from every index it was stored under. The index nodes leave tombstones in its place,
+// so the copies of the pair still being handed off or replicated do not bring it back.
+// The deletions are left unversioned for the index nodes to stamp after the versions they hold.
+// Fails with errs.NotFoundError when none of the index nodes held the pair.
+func (d *DistributedKV) Delete(ctx context.Context, key string, consistency node.Consistency) error {
+	indexes, err := d.indexes(key)
+	if err != nil {
+		return err
+	}
 
-	vals := make([]node.InsertItem, 0, len(split))
-	for _, token := range split {
-		vals = append(vals, node.InsertItem{
-			Index: token,
+	items := make([]node.DeleteItem, 0, len(indexes))
+	for _, index := range indexes {
+		items = append(items, node.DeleteItem{
+			Index: index,
 			Key:   key,
-			Value: value,
 		})
 	}
 
-	err := d.c.InsertBatch(ctx, vals...)
+	deleted, err := d.c.DeleteBatch(ctx, consistency, items...)
 	if err != nil {
 		return err
 	}
+	if deleted == 0 {
+		return errs.NotFoundError
+	}
 	return nil
 }
 
-func (d *DistributedKV) Get(ctx context.Context, query string) (string, error) {
-	query = strings.ToLower(query)
-	index := strings.SplitN(query, " ", 2)[0]
+// indexes returns the indexes a key is stored under, which are its distinct tokens, the leading PrefixLength letters
+// of the tokens and, when fuzzy, the deletion variants of the tokens.
+func (d *DistributedKV) indexes(key string) ([]string, error) {
+	tokens := analysis.Distinct(d.tokenizer.Tokenize(key))
+	if len(tokens) == 0 {
+		return nil, errs.EmptyKeyError
+	}
+
+	indexes := tokens
+	prefixes := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if prefix, ok := leading(token); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	for _, prefix := range analysis.Distinct(prefixes) {
+		indexes = append(indexes, node.PrefixIndex(prefix))
+	}
+
+	if d.fuzzy {
+		variants := make([]string, 0)
+		for _, token := range tokens {
+			if utf8.RuneCountInString(token) >= analysis.MinFuzzyLength {
+				variants = append(variants, token)
+				variants = append(variants, analysis.Deletions(token)...)
+			}
+		}
+
+		for _, variant := range analysis.Distinct(variants) {
+			indexes = append(indexes, node.FuzzyIndex(variant))
+		}
+	}
+
+	return indexes, nil
+}
+
+// leading returns the leading PrefixLength letters of the word, false when the word is shorter.
+func leading(word string) (string, bool) {
+	n := 0
+	for i := range word {
+		if n == PrefixLength {
+			return word[:i], true
+		}
+		n++
+	}
+
+	return word, n == PrefixLength
+}
+
+// Get returns the first KV pair inserted whose key holds the tokens of the query in order, along with its version.
+// The pair is found through the index of the rarest token and then read from the index of the first token of its key,
+// whose version the compare and swaps of the key match against, both at the consistency level.
+// When fuzzy and no key holds them, it returns the top hit of a fuzzy search for the tokens instead,
+// read from the index nodes regardless of the consistency level.
+func (d *DistributedKV) Get(ctx context.Context, q string, consistency node.Consistency, fuzzy bool) (Hit, error) {
+	if fuzzy && !d.fuzzy {
+		return Hit{}, errs.FuzzyDisabledError
+	}
+
+	tokens := d.tokenizer.Tokenize(q)
+	if len(tokens) == 0 {
+		return Hit{}, fmt.Errorf("%w: no searchable words", errs.InvalidQueryError)
+	}
+
+	plan := d.planner.Plan(ctx, tokens)
 	// TODO: Prioritize looking into local node first
-	value, err := d.c.Query(ctx, index, query)
+	item, err := d.c.Query(ctx, plan.Index, strings.Join(tokens, " "), "", consistency)
+	if errors.Is(err, errs.NotFoundError) && fuzzy {
+		terms := make([]query.Expr, 0, len(tokens))
+		for _, token := range tokens {
+			terms = append(terms, query.Term{Word: token})
+		}
+
+		result, err := d.search(ctx, query.And{Exprs: terms}, true, "", 1)
+		if err != nil {
+			return Hit{}, err
+		}
+		if len(result.Hits) == 0 {
+			return Hit{}, errs.NotFoundError
+		}
+
+		return result.Hits[0], nil
+	}
 	if err != nil {
-		return "", err
+		return Hit{}, err
 	}
 
-	return value, nil
+	item, err = d.authority(ctx, item, plan.Index, consistency)
+	if err != nil {
+		return Hit{}, err
+	}
+
+	return Hit{Key: item.Key, Value: item.Value, Version: item.Version}, nil
+}
+
+// authority returns the pair as held by the index of the first token of its key, given the copy read from another
+// index. The owner of the first index decides the compare and swaps of the key, so the version it holds is the one
+// the swaps match against, even while the rest of the indexes lag behind it. The first index is read at the consistency
+// level, failing with errs.NotFoundError when it no longer holds the key, or does not hold it yet.
+func (d *DistributedKV) authority(ctx context.Context, item node.InsertItem, index string, consistency node.Consistency) (node.InsertItem, error) {
+	indexes, err := d.indexes(item.Key)
+	if err != nil {
+		return node.InsertItem{}, err
+	}
+	if indexes[0] == index {
+		return item, nil
+	}
+
+	return d.c.Query(ctx, indexes[0], "", item.Key, consistency)
+}
+
+// Search lists the KV pairs whose keys match the query a page at a time, ranked by their BM25 scores
+// and then by key. See query.Parse for the syntax. The query is evaluated on the index nodes of its anchors,
+// and the document frequencies of its words are published by the index nodes of the words.
+// When fuzzy, the words match the ones within FuzzyDistance of them, ranked below the exact matches.
+func (d *DistributedKV) Search(ctx context.Context, q string, cursor string, limit int, fuzzy bool) (SearchResult, error) {
+	expr, err := query.Parse(q, d.tokenizer)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	return d.search(ctx, expr, fuzzy, cursor, limit)
+}
+
+func (d *DistributedKV) search(ctx context.Context, exact query.Expr, fuzzy bool, cursor string, limit int) (SearchResult, error) {
+	if fuzzy && !d.fuzzy {
+		return SearchResult{}, errs.FuzzyDisabledError
+	}
+
+	after, stats, err := decodeRankCursor(cursor)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	if limit < 1 {
+		limit = 1
+	}
+
+	expr, distance := exact, 0
+	if fuzzy {
+		expr, distance = query.Fuzz(exact, FuzzyDistance), FuzzyDistance
+	}
+
+	anchors, err := d.planner.Anchors(ctx, expr)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	words := distinctWords(expr)
+	if stats == nil {
+		stats = &rankStats{DF: d.planner.cardinalities(ctx, words)}
+		stats.Keys, stats.AvgLength = d.collectionStats()
+	}
+
+	search := node.RankedSearch{Expr: expr, Words: words, DF: stats.DF, Keys: stats.Keys, AvgLength: stats.AvgLength, Distance: distance, After: after.rank(), Limit: limit + 1}
+	if fuzzy {
+		search.Exact = exact
+	}
+
+	// Collect the hits of the page along with an extra one to tell whether there is a next page. The hits whose keys
+	// the first indexes no longer hold are left out, so the ranking goes on past them until the page fills up
+	hits := make([]Hit, 0, limit+1)
+	for len(hits) <= limit {
+		search.Limit = limit + 1 - len(hits)
+		ranked, err := d.rank(ctx, anchors, search)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		if len(ranked) == 0 {
+			break
+		}
+		last := ranked[len(ranked)-1]
+
+		resolved, err := d.authorities(ctx, ranked)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		hits = append(hits, resolved...)
+
+		if len(ranked) < search.Limit {
+			break
+		}
+		search.After = last.rank()
+	}
+
+	result := SearchResult{Hits: hits}
+	if len(hits) > limit {
+		result.Hits = hits[:limit]
+		result.Cursor = encodeRankCursor(hits[limit-1], *stats)
+	}
+
+	return result, nil
+}
+
+// authorities replaces the values and the versions of the hits with the ones held by the indexes of the first tokens
+// of their keys, as Get does at consistency level One, reading up to authorityConcurrency of them at a time.
+// The hits whose keys the first indexes no longer hold are left out.
+func (d *DistributedKV) authorities(ctx context.Context, hits []Hit) ([]Hit, error) {
+	found := make([]bool, len(hits))
+	failures := make([]error, len(hits))
+
+	sem := make(chan struct{}, authorityConcurrency)
+	wg := sync.WaitGroup{}
+	for i := range hits {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			item, err := d.authority(ctx, node.InsertItem{Key: hits[i].Key, Value: hits[i].Value, Version: hits[i].Version}, hits[i].index, node.One)
+			if err != nil {
+				if !errors.Is(err, errs.NotFoundError) {
+					failures[i] = err
+				}
+				return
+			}
+			hits[i].Value, hits[i].Version, found[i] = item.Value, item.Version, true
+		}(i)
+	}
+	wg.Wait()
+
+	resolved := hits[:0]
+	for i, hit := range hits {
+		if failures[i] != nil {
+			return nil, failures[i]
+		}
+		if found[i] {
+			resolved = append(resolved, hit)
+		}
+	}
+
+	return resolved, nil
+}
+
+// rank fetches the top matches of the search from the index node of each anchor in parallel and merges them,
+// keeping the top ones in rank order. Every match is held by one of the anchors, so the top matches of the search
+// are among the top ones of the anchors.
+func (d *DistributedKV) rank(ctx context.Context, anchors []Anchor, search node.RankedSearch) ([]Hit, error) {
+	ranked := make([][]node.RankedItem, len(anchors))
+	failures := make([]error, len(anchors))
+
+	wg := sync.WaitGroup{}
+	for i, anchor := range anchors {
+		wg.Add(1)
+		go func(i int, anchor Anchor) {
+			defer wg.Done()
+
+			s := search
+			s.Query = anchor.Query
+			ranked[i], failures[i] = d.c.Rank(ctx, anchor.Index, s)
+		}(i, anchor)
+	}
+	wg.Wait()
+
+	top := make([]node.RankedItem, 0, search.Limit)
+	indexes := make(map[string]string)
+	for i, items := range ranked {
+		if failures[i] != nil {
+			return nil, failures[i]
+		}
+
+		for _, item := range items {
+			if _, ok := indexes[item.Item.Key]; ok {
+				// Held by another anchor as well
+				continue
+			}
+			indexes[item.Item.Key] = anchors[i].Index
+			top = node.InsertRanked(top, item, search.Limit)
+		}
+	}
+
+	hits := make([]Hit, 0, len(top))
+	for _, item := range top {
+		hits = append(hits, Hit{Key: item.Item.Key, Value: item.Item.Value, Version: item.Item.Version, Score: item.Score, Fuzzy: item.Fuzzy, index: indexes[item.Item.Key]})
+	}
+
+	return hits, nil
+}
+
+// collectionStats returns the estimates of chord.Chord.CollectionStats, cached for CardinalityTTL.
+func (d *DistributedKV) collectionStats() (keys float64, avgLength float64) {
+	d.collection.lock.Lock()
+	defer d.collection.lock.Unlock()
+
+	if time.Since(d.collection.fetched) >= CardinalityTTL {
+		d.collection.keys, d.collection.avgLength = d.c.CollectionStats()
+		d.collection.fetched = time.Now()
+	}
+
+	return d.collection.keys, d.collection.avgLength
 }
 
 func (d *DistributedKV) Debug() string {
-	return d.c.Debug()
+	if len(d.vnodes) == 0 {
+		return d.c.Debug()
+	}
+
+	debug := make([]string, 0, len(d.vnodes)+1)
+	debug = append(debug, d.c.Debug())
+	for _, vnode := range d.vnodes {
+		debug = append(debug, vnode.Debug())
+	}
+
+	return "[" + strings.Join(debug, ",\n") + "]"
+}
+
+// DebugLookup describes the paths taken by the iterative lookups of the index nodes of the key,
+// which are the nodes of its distinct tokens.
+func (d *DistributedKV) DebugLookup(ctx context.Context, key string) string {
+	tokens := analysis.Distinct(d.tokenizer.Tokenize(key))
+	if len(tokens) == 1 {
+		return d.c.DebugLookup(ctx, tokens[0])
+	}
+
+	debug := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		debug = append(debug, d.c.DebugLookup(ctx, token))
+	}
+
+	return "[" + strings.Join(debug, ",\n") + "]"
 }
@@ -0,0 +1,507 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"github.com/yousuf64/chord-kv/analysis"
+	"github.com/yousuf64/chord-kv/chord"
+	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/hlc"
+	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/util"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKV(t *testing.T, tokenizer analysis.Tokenizer, fuzzy bool, keys ...string) *DistributedKV {
+	cfg := chord.DefaultConfig()
+	cfg.Space = util.NewSpace(3, 8)
+	cfg.Tokenizer = tokenizer
+	cfg.Fuzzy = fuzzy
+	c := chord.NewChord("node13", cfg)
+	err := c.Join(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDistributedKV(c)
+	for _, key := range keys {
+		_, err := d.Insert(context.Background(), key, key, 0, node.One)
+		if err != nil {
+			t.Fatalf("insert %s failed: %v", key, err)
+		}
+	}
+
+	return d
+}
+
+// searchAll pages through the hits of the query.
+func searchAll(t *testing.T, d *DistributedKV, query string, limit int, fuzzy bool) []Hit {
+	hits := make([]Hit, 0)
+	cursor := ""
+	for {
+		result, err := d.Search(context.Background(), query, cursor, limit, fuzzy)
+		if err != nil {
+			t.Fatalf("[%s] search failed: %v", query, err)
+		}
+		if len(result.Hits) > limit || (result.Cursor != "" && len(result.Hits) < limit) {
+			t.Fatalf("[%s] expected up to %d hits and full pages but the last, got %d", query, limit, len(result.Hits))
+		}
+
+		hits = append(hits, result.Hits...)
+		if result.Cursor == "" {
+			return hits
+		}
+		cursor = result.Cursor
+	}
+}
+
+func Test_Search(t *testing.T) {
+	d := newTestKV(t, analysis.Whitespace{}, false, "lord of the rings", "lord of war", "the lord of flies", "lord jim", "war and peace", "the hobbit")
+
+	search := func(query string, limit int) []string {
+		found := make([]string, 0)
+		hits := searchAll(t, d, query, limit, false)
+		for i, hit := range hits {
+			if i > 0 && hit.Score > hits[i-1].Score {
+				t.Fatalf("[%s] hits are not ranked by score: %v", query, hits)
+			}
+			found = append(found, hit.Key)
+		}
+
+		// Compare regardless of the ranking
+		sort.Strings(found)
+		return found
+	}
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "lord NOT war", expected: []string{"lord jim", "lord of the rings", "the lord of flies"}},
+		{query: `"lord of" OR hobbit`, expected: []string{"lord of the rings", "lord of war", "the hobbit", "the lord of flies"}},
+		{query: "war OR (the NOT lord)", expected: []string{"lord of war", "the hobbit", "war and peace"}},
+		{query: "the lor*", expected: []string{"lord of the rings", "the lord of flies"}},
+		{query: "lor*", expected: []string{"lord jim", "lord of the rings", "lord of war", "the lord of flies"}},
+		{query: "war* NOT lord", expected: []string{"war and peace"}},
+		{query: "hob* OR jim", expected: []string{"lord jim", "the hobbit"}},
+		{query: `"of the" OR "of war"`, expected: []string{"lord of the rings", "lord of war"}},
+		{query: "jim AND peace", expected: []string{}},
+	}
+	for _, c := range cases {
+		for _, limit := range []int{1, 2, 10} {
+			found := search(c.query, limit)
+			if len(found) != len(c.expected) {
+				t.Fatalf("[%s/%d] expected %v, got %v", c.query, limit, c.expected, found)
+			}
+			for i := range c.expected {
+				if found[i] != c.expected[i] {
+					t.Fatalf("[%s/%d] expected %v, got %v", c.query, limit, c.expected, found)
+				}
+			}
+		}
+	}
+
+	// The hits whose first indexes no longer hold their keys are left out, the pages filling up past them
+	_, err := d.c.DeleteBatch(context.Background(), node.One, node.DeleteItem{Index: "the", Key: "the lord of flies", Version: d.c.Clock().Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, limit := range []int{1, 2, 10} {
+		found := search("lord", limit)
+		expected := []string{"lord jim", "lord of the rings", "lord of war"}
+		if strings.Join(found, ",") != strings.Join(expected, ",") {
+			t.Fatalf("[lord/%d] expected %v, got %v", limit, expected, found)
+		}
+	}
+
+	for _, query := range []string{"lo*", "NOT lord", "lord OR NOT war", "lord AND"} {
+		_, err = d.Search(context.Background(), query, "", 10, false)
+		if !errors.Is(err, errs.InvalidQueryError) {
+			t.Fatalf("[%s] expected invalid query, got %v", query, err)
+		}
+	}
+}
+
+func Test_PlannerCache(t *testing.T) {
+	d := newTestKV(t, analysis.Whitespace{}, false, "lord of the rings", "lord of war")
+	p := NewPlanner(d.c, time.Minute, 2)
+	p.Plan(context.Background(), []string{"lord", "of"})
+	p.Plan(context.Background(), []string{"lord", "war"})
+	if len(p.cache) != 2 || p.lru.Len() != 2 {
+		t.Fatalf("expected 2 cached terms, got %d", len(p.cache))
+	}
+	if _, ok := p.cache["of"]; ok {
+		t.Fatalf("expected the least recently used term to be evicted")
+	}
+	if value, ok := p.cached("lord"); !ok || value != 2 {
+		t.Fatalf("expected the cardinality of lord cached as 2, got %d", value)
+	}
+
+	stale := NewPlanner(d.c, 0, 2)
+	stale.Plan(context.Background(), []string{"war"})
+	if _, ok := stale.cached("war"); ok || len(stale.cache) != 0 {
+		t.Fatalf("expected the stale cardinality to be dropped")
+	}
+}
+
+func Test_Rank(t *testing.T) {
+	d := newTestKV(t, analysis.Whitespace{}, false, "lord of the rings", "the lord of flies", "lord jim", "the hobbit", "the silmarillion", "the two towers")
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		// Shorter keys rank first
+		{query: "lord", expected: []string{"lord jim", "lord of the rings", "the lord of flies"}},
+		// Rarer words weigh more
+		{query: "the OR hobbit", expected: []string{"the hobbit", "the silmarillion", "the two towers", "lord of the rings", "the lord of flies"}},
+		{query: "lord the", expected: []string{"lord of the rings", "the lord of flies"}},
+	}
+	for _, c := range cases {
+		for _, limit := range []int{1, 2, 10} {
+			hits := searchAll(t, d, c.query, limit, false)
+			if len(hits) != len(c.expected) {
+				t.Fatalf("[%s/%d] expected %v, got %v", c.query, limit, c.expected, hits)
+			}
+			for i := range c.expected {
+				if hits[i].Key != c.expected[i] || hits[i].Score <= 0 {
+					t.Fatalf("[%s/%d] expected %v, got %v", c.query, limit, c.expected, hits)
+				}
+			}
+		}
+	}
+
+	_, err := d.Search(context.Background(), "lord", "bm90IGEgY3Vyc29y", 10, false)
+	if !errors.Is(err, errs.InvalidCursorError) {
+		t.Fatalf("expected invalid cursor, got %v", err)
+	}
+
+	// The later pages rank with the statistics of the first one
+	first, err := d.Search(context.Background(), "lord", "", 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.Insert(context.Background(), "lord of war", "lord of war", 0, node.One)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.planner = NewPlanner(d.c, CardinalityTTL, CardinalityCacheSize)
+
+	second, err := d.Search(context.Background(), "lord", first.Cursor, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stats, err := decodeRankCursor(second.Cursor)
+	if err != nil || stats.DF["lord"] != 3 {
+		t.Fatalf("expected the statistics of the first page, got %v: %v", stats, err)
+	}
+	if len(second.Hits) != 1 || !first.Hits[0].rank().Before(second.Hits[0].rank()) {
+		t.Fatalf("expected the second page after the first one, got %v then %v", first.Hits, second.Hits)
+	}
+}
+
+func Test_Tokenizer(t *testing.T) {
+	d := newTestKV(t, analysis.NewStandard(true), false, "The Lord of the Rings", "lord, LORD of war!", "Running  with\twolves", "Café Society")
+
+	_, err := d.Insert(context.Background(), "the of and", "stop words", 0, node.One)
+	if !errors.Is(err, errs.EmptyKeyError) {
+		t.Fatalf("expected empty key, got %v", err)
+	}
+
+	hit, err := d.Get(context.Background(), "LORD war", node.One, false)
+	if err != nil || hit.Value != "lord, LORD of war!" {
+		t.Fatalf("expected the value of the repeated words, got %q: %v", hit.Value, err)
+	}
+
+	_, err = d.Get(context.Background(), "of the", node.One, false)
+	if !errors.Is(err, errs.InvalidQueryError) {
+		t.Fatalf("expected invalid query, got %v", err)
+	}
+
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{query: `"lord of the rings"`, expected: "The Lord of the Rings"},
+		{query: "run", expected: "Running  with\twolves"},
+		{query: "wolves NOT lord", expected: "Running  with\twolves"},
+		{query: "CAFÉ", expected: "Café Society"},
+		{query: "Rings*", expected: "The Lord of the Rings"},
+		{query: "caf*", expected: "Café Society"},
+	}
+	for _, c := range cases {
+		hits := searchAll(t, d, c.query, 10, false)
+		if len(hits) != 1 || hits[0].Key != c.expected {
+			t.Fatalf("[%s] expected %q, got %v", c.query, c.expected, hits)
+		}
+	}
+}
+
+func Test_Fuzzy(t *testing.T) {
+	d := newTestKV(t, analysis.NewStandard(false), true, "The Lord of the Rings", "Lords of Dogtown", "The Hobbit", "Lord Jim")
+
+	cases := []struct {
+		query    string
+		expected []string
+		fuzzy    []bool
+	}{
+		// Exact matches rank above the fuzzy ones
+		{query: "lord", expected: []string{"Lord Jim", "The Lord of the Rings", "Lords of Dogtown"}, fuzzy: []bool{false, false, true}},
+		{query: "lrod rigns", expected: []string{"The Lord of the Rings"}, fuzzy: []bool{true}},
+		{query: "hobbbit OR jim", expected: []string{"Lord Jim", "The Hobbit"}, fuzzy: []bool{false, true}},
+		{query: "dogtwn NOT lords", expected: []string{}, fuzzy: []bool{}},
+		// Too short to be matched fuzzily
+		{query: "jin", expected: []string{}, fuzzy: []bool{}},
+	}
+	for _, c := range cases {
+		for _, limit := range []int{1, 10} {
+			hits := searchAll(t, d, c.query, limit, true)
+			if len(hits) != len(c.expected) {
+				t.Fatalf("[%s/%d] expected %v, got %v", c.query, limit, c.expected, hits)
+			}
+			for i := range c.expected {
+				if hits[i].Key != c.expected[i] || hits[i].Fuzzy != c.fuzzy[i] {
+					t.Fatalf("[%s/%d] expected %v (fuzzy %v), got %v", c.query, limit, c.expected, c.fuzzy, hits)
+				}
+			}
+		}
+	}
+
+	if hits := searchAll(t, d, "lrod", 10, false); len(hits) != 0 {
+		t.Fatalf("expected no exact hits, got %v", hits)
+	}
+
+	hit, err := d.Get(context.Background(), "hobit", node.One, true)
+	if err != nil || hit.Value != "The Hobbit" {
+		t.Fatalf("expected the fuzzy get to find the hobbit, got %q: %v", hit.Value, err)
+	}
+	_, err = d.Get(context.Background(), "hobit", node.One, false)
+	if !errors.Is(err, errs.NotFoundError) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	exact := newTestKV(t, analysis.NewStandard(false), false, "The Hobbit")
+	_, err = exact.Search(context.Background(), "hobit", "", 10, true)
+	if !errors.Is(err, errs.FuzzyDisabledError) {
+		t.Fatalf("expected fuzzy search to be disabled, got %v", err)
+	}
+}
+
+func Test_Delete(t *testing.T) {
+	d := newTestKV(t, analysis.Whitespace{}, true, "lord of the rings", "lord of war")
+
+	err := d.Delete(context.Background(), "lord of the rings", node.One)
+	if err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	_, err = d.Get(context.Background(), "rings", node.One, false)
+	if !errors.Is(err, errs.NotFoundError) {
+		t.Fatalf("expected the deleted key not found, got %v", err)
+	}
+
+	for _, fuzzy := range []bool{false, true} {
+		hits := searchAll(t, d, "lord", 10, fuzzy)
+		if len(hits) != 1 || hits[0].Key != "lord of war" {
+			t.Fatalf("[fuzzy %v] expected only the remaining key, got %+v", fuzzy, hits)
+		}
+	}
+	if hits := searchAll(t, d, "ringz", 10, true); len(hits) != 0 {
+		t.Fatalf("expected the fuzzy variants deleted, got %+v", hits)
+	}
+
+	err = d.Delete(context.Background(), "lord of the rings", node.One)
+	if !errors.Is(err, errs.NotFoundError) {
+		t.Fatalf("expected deleting again to fail with not found, got %v", err)
+	}
+
+	err = d.Delete(context.Background(), "  ", node.One)
+	if !errors.Is(err, errs.EmptyKeyError) {
+		t.Fatalf("expected an empty key to fail, got %v", err)
+	}
+}
+
+func Test_Put(t *testing.T) {
+	d := newTestKV(t, analysis.Whitespace{}, false, "lord of the rings")
+
+	_, err := d.Insert(context.Background(), "lord of the rings", "again", 0, node.One)
+	if !errors.Is(err, errs.AlreadyExistsError) {
+		t.Fatalf("expected inserting again to fail, got %v", err)
+	}
+
+	_, err = d.Update(context.Background(), "lord of war", "war", 0, node.One)
+	if !errors.Is(err, errs.NotFoundError) {
+		t.Fatalf("expected updating a missing key to fail, got %v", err)
+	}
+
+	put, err := d.Put(context.Background(), "lord of war", "war", 0, node.One)
+	if err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	updated, err := d.Update(context.Background(), "lord of the rings", "updated", 0, node.One)
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if updated <= put {
+		t.Fatalf("expected the later write of a later version, got %s after %s", updated, put)
+	}
+
+	// Every word of the key answers with the new value and its version
+	for _, q := range []string{"lord of", "the", "rings"} {
+		hit, err := d.Get(context.Background(), q, node.One, false)
+		if err != nil || hit.Value != "updated" || hit.Version != updated {
+			t.Fatalf("[%s] expected the updated value, got %q: %v", q, hit.Value, err)
+		}
+	}
+	if hit, _ := d.Get(context.Background(), "war", node.One, false); hit.Value != "war" || hit.Version != put {
+		t.Fatalf("expected the put value, got %q", hit.Value)
+	}
+}
+
+func Test_CompareAndSwap(t *testing.T) {
+	d := newTestKV(t, analysis.Whitespace{}, false, "lord of the rings")
+
+	hit, err := d.Get(context.Background(), "lord", node.One, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = d.CompareAndSwap(context.Background(), "lord of the rings", hit.Version+1, "stale", 0, node.One)
+	if !errors.Is(err, errs.VersionMismatchError) {
+		t.Fatalf("expected a version mismatch, got %v", err)
+	}
+	// A version far ahead of the clock fails without the clock observing it
+	_, err = d.CompareAndSwap(context.Background(), "lord of the rings", math.MaxUint64, "bogus", 0, node.One)
+	if !errors.Is(err, errs.VersionMismatchError) {
+		t.Fatalf("expected a version mismatch, got %v", err)
+	}
+	if now := d.c.Clock().Now(); !d.c.Clock().Plausible(now) {
+		t.Fatalf("expected the clock left behind the bogus version, got %s", now)
+	}
+	_, err = d.CompareAndSwap(context.Background(), "lord of war", hit.Version, "war", 0, node.One)
+	if !errors.Is(err, errs.NotFoundError) {
+		t.Fatalf("expected swapping a missing key to fail, got %v", err)
+	}
+
+	version, err := d.CompareAndSwap(context.Background(), "lord of the rings", hit.Version, "swapped", 0, node.One)
+	if err != nil || version <= hit.Version {
+		t.Fatalf("expected the swap to succeed with a later version, got %s: %v", version, err)
+	}
+
+	// Every word of the key answers with the swapped value, and the version swapped away no longer matches
+	for _, q := range []string{"lord of", "the", "rings"} {
+		hit, err := d.Get(context.Background(), q, node.One, false)
+		if err != nil || hit.Value != "swapped" || hit.Version != version {
+			t.Fatalf("[%s] expected the swapped value, got %q: %v", q, hit.Value, err)
+		}
+	}
+	_, err = d.CompareAndSwap(context.Background(), "lord of the rings", hit.Version, "again", 0, node.One)
+	if !errors.Is(err, errs.VersionMismatchError) {
+		t.Fatalf("expected a version mismatch, got %v", err)
+	}
+
+	// Two swaps in a row whose puts to the rest of the indexes land out of order settle on the later swap
+	indexes, _ := d.indexes("lord of the rings")
+	swap := func(expected hlc.Timestamp, value string) node.InsertItem {
+		item := node.InsertItem{Index: indexes[0], Key: "lord of the rings", Value: value, Mode: node.Update, Version: max(d.c.Clock().Now(), expected+1)}
+		err := d.c.CompareAndSwap(context.Background(), node.One, item, expected)
+		if err != nil {
+			t.Fatalf("swap to %s failed: %v", value, err)
+		}
+		item.Mode = node.Upsert
+		return item
+	}
+	first := swap(version, "first")
+	second := swap(first.Version, "second")
+	for _, item := range []node.InsertItem{second, first} {
+		err = d.c.InsertBatch(context.Background(), node.One, items(indexes[1:], item)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, q := range []string{"lord of", "the", "rings"} {
+		hit, err := d.Get(context.Background(), q, node.One, false)
+		if err != nil || hit.Value != "second" || hit.Version != second.Version {
+			t.Fatalf("[%s] expected the later swap, got %q: %v", q, hit.Value, err)
+		}
+	}
+
+	// A swap failing before reaching the rest of the indexes leaves them behind, while the reads and the searches
+	// through them return the version of the first index, which the next swap matches
+	third := swap(second.Version, "third")
+	for _, q := range []string{"rings", "lord of"} {
+		hit, err := d.Get(context.Background(), q, node.One, false)
+		if err != nil || hit.Value != "third" || hit.Version != third.Version {
+			t.Fatalf("[%s] expected the version of the first index, got %q: %v", q, hit.Value, err)
+		}
+	}
+	hits := searchAll(t, d, "rings", 10, false)
+	if len(hits) != 1 || hits[0].Value != "third" || hits[0].Version != third.Version {
+		t.Fatalf("expected the version of the first index, got %v", hits)
+	}
+	_, err = d.CompareAndSwap(context.Background(), "lord of the rings", hits[0].Version, "fourth", 0, node.One)
+	if err != nil {
+		t.Fatalf("expected the swap to match the version read, got %v", err)
+	}
+}
+
+func Test_TTL(t *testing.T) {
+	d := newTestKV(t, analysis.Whitespace{}, false)
+
+	_, err := d.Insert(context.Background(), "session of alice", "token", 20*time.Millisecond, node.One)
+	if err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	for _, q := range []string{"session", "alice"} {
+		if _, err = d.Get(context.Background(), q, node.One, false); err != nil {
+			t.Fatalf("[%s] expected the live key found: %v", q, err)
+		}
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	// Every index leaves the key out once expired, and the key can be created again
+	for _, q := range []string{"session", "alice"} {
+		if _, err = d.Get(context.Background(), q, node.One, false); !errors.Is(err, errs.NotFoundError) {
+			t.Fatalf("[%s] expected the expired key left out, got %v", q, err)
+		}
+	}
+	result, err := d.Search(context.Background(), "session", "", 10, false)
+	if err != nil || len(result.Hits) != 0 {
+		t.Fatalf("expected no hits of the expired key, got %v: %v", result.Hits, err)
+	}
+
+	_, err = d.Insert(context.Background(), "session of alice", "renewed", 0, node.One)
+	if err != nil {
+		t.Fatalf("expected the expired key created again: %v", err)
+	}
+	if hit, _ := d.Get(context.Background(), "alice", node.One, false); hit.Value != "renewed" {
+		t.Fatalf("expected the renewed value, got %q", hit.Value)
+	}
+
+	// Every write sets the deadline anew, a write without a ttl making the key permanent
+	version, err := d.Put(context.Background(), "session of alice", "leased", 20*time.Millisecond, node.One)
+	if err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	_, err = d.CompareAndSwap(context.Background(), "session of alice", version, "renewed", 20*time.Millisecond, node.One)
+	if err != nil {
+		t.Fatalf("swap failed: %v", err)
+	}
+	_, err = d.Update(context.Background(), "session of alice", "permanent", 0, node.One)
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	for _, q := range []string{"session", "alice"} {
+		if hit, err := d.Get(context.Background(), q, node.One, false); err != nil || hit.Value != "permanent" {
+			t.Fatalf("[%s] expected the key made permanent, got %q: %v", q, hit.Value, err)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package kv
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"github.com/yousuf64/chord-kv/analysis"
+	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/query"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Plan tells which index node answers a query.
+type Plan struct {
+	// Index is the term of the query whose index node answers it.
+	Index string
+	// Cardinalities holds the number of items of each term of the query, as published by the nodes owning them.
+	// Terms whose owners did not respond are missing.
+	Cardinalities map[string]int
+}
+
+type cardinality struct {
+	term    string
+	value   int
+	fetched time.Time
+}
+
+// Planner picks the rarest term of a query to look it up by. Every word of a key indexes the whole KV pair,
+// so the index node of any term of the query holds all the matches, and the one of the rarest term filters the fewest.
+type Planner struct {
+	node node.Node
+	ttl  time.Duration
+	size int
+
+	lock  sync.Mutex
+	cache map[string]*list.Element // Term -> cardinality
+	lru   *list.List               // Most recently used first
+}
+
+// NewPlanner creates a planner caching the cardinalities published by the index nodes for ttl,
+// evicting the least recently used ones beyond size terms.
+func NewPlanner(n node.Node, ttl time.Duration, size int) *Planner {
+	return &Planner{node: n, ttl: ttl, size: size, cache: map[string]*list.Element{}, lru: list.New()}
+}
+
+// Plan fetches the cardinalities of the terms of the query from their index nodes in parallel and picks the rarest term.
+// Falls back to the first term when none of the index nodes respond.
+func (p *Planner) Plan(ctx context.Context, terms []string) Plan {
+	plan := Plan{Index: terms[0], Cardinalities: p.cardinalities(ctx, terms)}
+
+	rarest := -1
+	for _, term := range terms {
+		value, ok := plan.Cardinalities[term]
+		if ok && (rarest == -1 || value < rarest) {
+			plan.Index, rarest = term, value
+		}
+	}
+
+	return plan
+}
+
+// Anchor is an index holding every match of a branch of a query, along with a query in the form of Chord.Search
+// the index node narrows its items down by. An empty query lists all the items of the index.
+type Anchor struct {
+	Index string
+	Query string
+}
+
+// Anchors picks the indexes to evaluate the expression on. Every match of the expression is held by at least one of them.
+// A conjunction is anchored by its rarest operand and a disjunction by all of its operands. A prefix is anchored by
+// the index of its leading PrefixLength letters, so the shorter prefixes and the negations need a word, a phrase
+// or a longer prefix next to them to be looked up by.
+func (p *Planner) Anchors(ctx context.Context, expr query.Expr) ([]Anchor, error) {
+	cardinalities := p.cardinalities(ctx, append(words(expr, nil), prefixIndexes(expr, nil)...))
+	cost := func(word string) int {
+		if value, ok := cardinalities[word]; ok {
+			return value
+		}
+		return math.MaxInt32
+	}
+
+	anchors, _, ok := anchor(expr, cost)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s needs a word, a phrase or a prefix of %d letters every match holds", errs.InvalidQueryError, expr, PrefixLength)
+	}
+
+	return anchors, nil
+}
+
+func anchor(expr query.Expr, cost func(word string) int) ([]Anchor, int, bool) {
+	switch e := expr.(type) {
+	case query.Term:
+		return []Anchor{{Index: e.Word, Query: e.Word}}, cost(e.Word), true
+	case query.Fuzzy:
+		// The keys holding a token within an edit of the word share the word or one of its deletion variants.
+		// Their cardinalities are unknown, so the fuzzy words anchor a conjunction only when nothing else does,
+		// the longest one first as its variants are the most selective.
+		variants := append([]string{e.Word}, analysis.Deletions(e.Word)...)
+		anchors := make([]Anchor, 0, len(variants))
+		for _, variant := range variants {
+			anchors = append(anchors, Anchor{Index: node.FuzzyIndex(variant)})
+		}
+		return anchors, math.MaxInt32 - utf8.RuneCountInString(e.Word), true
+	case query.Prefix:
+		prefix, ok := leading(e.Prefix)
+		if !ok {
+			return nil, 0, false
+		}
+		index := node.PrefixIndex(prefix)
+		return []Anchor{{Index: index}}, cost(index), true
+	case query.Phrase:
+		rarest := e.Words[0]
+		for _, word := range e.Words[1:] {
+			if cost(word) < cost(rarest) {
+				rarest = word
+			}
+		}
+		return []Anchor{{Index: rarest, Query: strings.Join(e.Words, " ")}}, cost(rarest), true
+	case query.And:
+		var best []Anchor
+		bestCost := 0
+		for _, operand := range e.Exprs {
+			anchors, c, ok := anchor(operand, cost)
+			if ok && (best == nil || c < bestCost) {
+				best, bestCost = anchors, c
+			}
+		}
+		return best, bestCost, best != nil
+	case query.Or:
+		anchors := make([]Anchor, 0, len(e.Exprs))
+		total := 0
+		for _, operand := range e.Exprs {
+			a, c, ok := anchor(operand, cost)
+			if !ok {
+				return nil, 0, false
+			}
+			for _, it := range a {
+				if !slices.Contains(anchors, it) {
+					anchors = append(anchors, it)
+				}
+			}
+			total += c
+		}
+		return anchors, total, true
+	default:
+		return nil, 0, false
+	}
+}
+
+// distinctWords returns the distinct words the expression can be anchored by, which are also the ones scoring its matches.
+func distinctWords(expr query.Expr) []string {
+	distinct := make([]string, 0)
+	for _, word := range words(expr, nil) {
+		if !slices.Contains(distinct, word) {
+			distinct = append(distinct, word)
+		}
+	}
+
+	return distinct
+}
+
+// words collects the words the expression can be anchored by.
+func words(expr query.Expr, acc []string) []string {
+	switch e := expr.(type) {
+	case query.Term:
+		return append(acc, e.Word)
+	case query.Fuzzy:
+		return append(acc, e.Word)
+	case query.Phrase:
+		return append(acc, e.Words...)
+	case query.And:
+		for _, operand := range e.Exprs {
+			acc = words(operand, acc)
+		}
+	case query.Or:
+		for _, operand := range e.Exprs {
+			acc = words(operand, acc)
+		}
+	}
+
+	return acc
+}
+
+// prefixIndexes collects the indexes the prefixes of the expression can be anchored by.
+func prefixIndexes(expr query.Expr, acc []string) []string {
+	switch e := expr.(type) {
+	case query.Prefix:
+		if prefix, ok := leading(e.Prefix); ok {
+			return append(acc, node.PrefixIndex(prefix))
+		}
+	case query.And:
+		for _, operand := range e.Exprs {
+			acc = prefixIndexes(operand, acc)
+		}
+	case query.Or:
+		for _, operand := range e.Exprs {
+			acc = prefixIndexes(operand, acc)
+		}
+	}
+
+	return acc
+}
+
+// cardinalities fetches the cardinalities of the terms from their index nodes in parallel.
+// Terms whose index nodes did not respond are left out.
+func (p *Planner) cardinalities(ctx context.Context, terms []string) map[string]int {
+	// The terms are deduplicated up front, leaving the goroutines the only writers of the map, under the lock
+	distinct := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if !slices.Contains(distinct, term) {
+			distinct = append(distinct, term)
+		}
+	}
+	cardinalities := make(map[string]int, len(distinct))
+
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, term := range distinct {
+		wg.Add(1)
+		go func(term string) {
+			defer wg.Done()
+
+			value, err := p.cardinality(ctx, term)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				log.Printf("Plan: failed to fetch the cardinality of %q: %v\n", term, err)
+				return
+			}
+			cardinalities[term] = value
+		}(term)
+	}
+	wg.Wait()
+
+	return cardinalities
+}
+
+func (p *Planner) cardinality(ctx context.Context, term string) (int, error) {
+	if value, ok := p.cached(term); ok {
+		return value, nil
+	}
+
+	value, err := p.node.Cardinality(ctx, term)
+	if err != nil {
+		return 0, err
+	}
+
+	p.store(term, value)
+	return value, nil
+}
+
+// cached returns the cardinality of the term unless missing or stale, dropping it when stale.
+func (p *Planner) cached(term string) (int, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	elem, ok := p.cache[term]
+	if !ok {
+		return 0, false
+	}
+	if time.Since(elem.Value.(cardinality).fetched) >= p.ttl {
+		p.lru.Remove(elem)
+		delete(p.cache, term)
+		return 0, false
+	}
+
+	p.lru.MoveToFront(elem)
+	return elem.Value.(cardinality).value, true
+}
+
+// store caches the cardinality of the term, evicting the least recently used terms beyond the size of the cache.
+func (p *Planner) store(term string, value int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	entry := cardinality{term: term, value: value, fetched: time.Now()}
+	if elem, ok := p.cache[term]; ok {
+		elem.Value = entry
+		p.lru.MoveToFront(elem)
+		return
+	}
+
+	p.cache[term] = p.lru.PushFront(entry)
+	for p.lru.Len() > p.size {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		delete(p.cache, oldest.Value.(cardinality).term)
+	}
+}
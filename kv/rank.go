@@ -0,0 +1,74 @@
+package kv
+
+import (
+	"encoding/json"
+	"github.com/yousuf64/chord-kv/chord"
+	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/hlc"
+	"github.com/yousuf64/chord-kv/query"
+	"math"
+)
+
+// Hit is a KV pair matching a search query, along with its relevance to the query.
+type Hit struct {
+	Key   string
+	Value string
+	// Version is the version of the pair, as stamped by its latest write.
+	Version hlc.Timestamp
+	Score   float64
+	// Fuzzy tells that the key matches the query only within the edit distance.
+	Fuzzy bool
+	// index is the index the pair was read from.
+	index string
+}
+
+type SearchResult struct {
+	Hits   []Hit
+	Cursor string
+}
+
+// rank returns the position of the hit among the hits of the search.
+func (h Hit) rank() query.Rank {
+	return query.Rank{Fuzzy: h.Fuzzy, Score: h.Score, Key: h.Key}
+}
+
+// rankStats are the collection statistics a search is ranked with. The first page of a search snapshots them
+// into its cursor, so that the later pages rank the hits the same way even when the statistics change in between.
+type rankStats struct {
+	Keys      float64        `json:"keys"`
+	AvgLength float64        `json:"avg_length"`
+	DF        map[string]int `json:"df"`
+}
+
+// rankCursor is the position of the last hit of a page, along with the statistics the search is ranked with.
+type rankCursor struct {
+	Fuzzy bool      `json:"fuzzy,omitempty"`
+	Score float64   `json:"score"`
+	Key   string    `json:"key"`
+	Stats rankStats `json:"stats"`
+}
+
+func encodeRankCursor(h Hit, stats rankStats) string {
+	data, _ := json.Marshal(rankCursor{Fuzzy: h.Fuzzy, Score: h.Score, Key: h.Key, Stats: stats})
+	return chord.EncodeCursor(string(data))
+}
+
+// decodeRankCursor returns the position of the last hit of the previous page and the statistics of the search.
+// An empty cursor decodes to a position ranking before any hit and no statistics.
+func decodeRankCursor(cursor string) (Hit, *rankStats, error) {
+	if cursor == "" {
+		return Hit{Score: math.Inf(1)}, nil, nil
+	}
+
+	decoded, err := chord.DecodeCursor(cursor)
+	if err != nil {
+		return Hit{}, nil, err
+	}
+
+	var c rankCursor
+	if err := json.Unmarshal([]byte(decoded), &c); err != nil || c.Stats.DF == nil {
+		return Hit{}, nil, errs.InvalidCursorError
+	}
+
+	return Hit{Fuzzy: c.Fuzzy, Score: c.Score, Key: c.Key}, &c.Stats, nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"github.com/yousuf64/chord-kv/analysis"
 	"github.com/yousuf64/chord-kv/bootstrap"
 	"github.com/yousuf64/chord-kv/chord"
 	"github.com/yousuf64/chord-kv/kv"
@@ -30,7 +31,17 @@ var dns = flag.String("dns", "", "public dns")
 var bootstrapAddr = flag.String("bootstrap", "localhost:55555", "bootstrap address")
 var username = flag.String("username", "sugarcane", "username")
 var m = flag.Int("M", 3, "M")
-var ringSize = flag.Uint("ringSize", 9, "ring size")
+var ringSize = flag.Uint("ringSize", 9, "ring size, 0 spans 2^M")
+var successors = flag.Int("successors", 3, "successor list size")
+var replicas = flag.Int("replicas", 2, "replication factor")
+var vnodes = flag.Int("vnodes", 1, "virtual nodes per host")
+var capacity = flag.Float64("capacity", 1, "capacity of the host, weights the number of virtual nodes")
+var lookup = flag.String("lookup", "recursive", "lookup mode, recursive or iterative")
+var transferChunk = flag.Int("transferChunk", 1000, "number of items per chunk of a transfer")
+var transferInterval = flag.Duration("transferInterval", 0, "pause between the chunks of a transfer")
+var dataDir = flag.String("data-dir", "", "directory persisting the items, kept in memory only when empty")
+var tokenizerName = flag.String("tokenizer", "standard", "tokenizer of the keys and the queries, standard, standard+stem or whitespace")
+var fuzzy = flag.Bool("fuzzy", false, "index the deletion variants of the tokens for the fuzzy searches")
 
 func main() {
 	flag.Parse()
@@ -41,7 +52,16 @@ func main() {
 		*dns = *addr
 	}
 
-	log.Printf("Host: %s | DNS: %s | Bootstrap Server: %s | Username: %s | Node ID: %d | M: %d | Ring Size: %d\n", *addr, *dns, *bootstrapAddr, *username, util.Hash(*addr), *m, *ringSize)
+	space := util.NewSpace(*m, uint64(*ringSize))
+	lookupMode, err := chord.ParseLookupMode(*lookup)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokenizer, err := analysis.Parse(*tokenizerName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Host: %s | DNS: %s | Bootstrap Server: %s | Username: %s | Node ID: %s | M: %d | Ring Size: %d\n", *addr, *dns, *bootstrapAddr, *username, space.Hash(*addr), *m, *ringSize)
 
 	jaegerEndpoint, ok := os.LookupEnv("OTEL_EXPORTER_JAEGER_ENDPOINT")
 	if !ok {
@@ -49,9 +69,6 @@ func main() {
 	}
 	log.Printf("Jaeger Endpoint: %s\n", jaegerEndpoint)
 
-	util.M = *m
-	util.RingSize = *ringSize
-
 	shutdown := initTracer(fmt.Sprintf("%s/%s", *addr, *username))
 	defer shutdown()
 
@@ -92,8 +109,21 @@ func main() {
 		),
 	)
 
-	ch := chord.NewChord(*addr)
-	dkv := kv.NewDistributedKV(ch)
+	cfg := chord.DefaultConfig()
+	cfg.Space = space
+	cfg.SuccessorListSize = *successors
+	cfg.ReplicationFactor = *replicas
+	cfg.LookupMode = lookupMode
+	cfg.TransferChunkSize = *transferChunk
+	cfg.TransferInterval = *transferInterval
+	cfg.DataDir = *dataDir
+	cfg.Tokenizer = tokenizer
+	cfg.Fuzzy = *fuzzy
+
+	chords := chord.NewVirtualNodes(*addr, chord.VirtualNodeCount(*vnodes, *capacity), cfg)
+	ch := chords[0]
+	dkv := kv.NewDistributedKV(ch, chords[1:]...)
+	log.Printf("Hosting %d virtual nodes\n", len(chords))
 
 	r := router.New(grpcServer, dkv)
 
@@ -108,7 +138,11 @@ func main() {
 		Handler: h2c.NewHandler(r, h2s),
 	}
 
-	transport.RegisterPeerServer(grpcServer, peerserver.New(ch))
+	peers := make([]chord.ChordNode, 0, len(chords)-1)
+	for _, vnode := range chords[1:] {
+		peers = append(peers, vnode)
+	}
+	transport.RegisterPeerServer(grpcServer, peerserver.New(ch, peers...))
 
 	sigint := make(chan os.Signal, 1)
 	signal.Notify(sigint, os.Interrupt, os.Kill)
@@ -119,18 +153,30 @@ func main() {
 
 		log.Println("Starting graceful shutdown")
 
+		bs.Unregister(*dns, *username)
+		for i := len(chords) - 1; i >= 0; i-- {
+			// Leave keeps the items until the successor takes them over, so a failed attempt can be retried
+			for attempt := 1; attempt <= 3; attempt++ {
+				err := chords[i].Leave(context.Background())
+				if err == nil {
+					break
+				}
+				log.Printf("failed to leave the ring (attempt %d): %v\n", attempt, err)
+			}
+
+			err := chords[i].Close()
+			if err != nil {
+				log.Printf("failed to close the storage: %v\n", err)
+			}
+		}
+
 		// We received an interrupt signal, shut down.
+		// The server keeps on serving until the successors pulled the items handed off on leaving.
 		if err := h1s.Shutdown(context.Background()); err != nil {
 			// Error from closing listeners, or context timeout:
 			log.Printf("HTTP server Shutdown: %v", err)
 		}
 
-		bs.Unregister(*dns, *username)
-		err := ch.Leave(context.Background())
-		if err != nil {
-			// TODO:
-		}
-
 		close(idleConnsClosed)
 	}()
 
@@ -142,7 +188,7 @@ func main() {
 	}()
 
 	if joinAddr != "" {
-		err = ch.Join(context.Background(), remote.NewRemoteNode(joinAddr))
+		err = ch.Join(context.Background(), remote.NewRemoteNode(joinAddr, space))
 		if err != nil {
 			log.Printf("failed to join node %s: %v", joinAddr, err)
 			sigint <- os.Interrupt
@@ -153,6 +199,17 @@ func main() {
 
 	if err == nil {
 		ch.StartJobs()
+
+		// The rest of the virtual nodes join the ring through the first one
+		for _, vnode := range chords[1:] {
+			err := vnode.Join(context.Background(), ch)
+			if err != nil {
+				log.Printf("failed to join virtual node %s: %v", vnode.ID(), err)
+				continue
+			}
+
+			vnode.StartJobs()
+		}
 	}
 
 	<-idleConnsClosed
@@ -1,23 +1,303 @@
 package node
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"github.com/yousuf64/chord-kv/hlc"
+	"github.com/yousuf64/chord-kv/query"
+	"github.com/yousuf64/chord-kv/util"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
 
 type Node interface {
-	ID() uint64
+	ID() util.ID
 	Addr() string
-	FindSuccessor(ctx context.Context, id uint64) (Node, error)
+	Handshake(ctx context.Context, hs Handshake) (Handshake, error)
+	FindSuccessor(ctx context.Context, id util.ID, route Route) (Node, error)
+	// NextHop returns the successor of the id when the node knows it, in which case done is true,
+	// and otherwise the closest preceding node to continue an iterative lookup from.
+	NextHop(ctx context.Context, id util.ID) (next Node, done bool, err error)
 	SetSuccessor(ctx context.Context, successor Node) error
 	SetPredecessor(ctx context.Context, predecessor Node) error
-	Notify(ctx context.Context, pn Node) ([]InsertItem, error)
+	Notify(ctx context.Context, pn Node) (Handoff, error)
+	// AcceptHandoff pulls the items of the handoff from the node offering them and confirms the handoff.
+	AcceptHandoff(ctx context.Context, from Node, handoff Handoff) error
+	// Transfer streams the items of the pending handoff in chunks, calling send for each chunk.
+	Transfer(ctx context.Context, id string, send func(chunk TransferChunk) error) error
+	ConfirmHandoff(ctx context.Context, id string) error
 	GetPredecessor(ctx context.Context) (Node, error)
+	GetSuccessorList(ctx context.Context) ([]Node, error)
 	Healthz(ctx context.Context) error
 
-	InsertBatch(ctx context.Context, items ...InsertItem) error
-	Query(ctx context.Context, index string, query string) (string, error)
+	InsertBatch(ctx context.Context, consistency Consistency, items ...InsertItem) error
+	// CompareAndSwap replaces the value of the item on the node owning it when the item is of the expected version,
+	// failing with errs.VersionMismatchError otherwise, and with errs.NotFoundError when the item is missing.
+	// The owner stamps the item when unversioned.
+	CompareAndSwap(ctx context.Context, consistency Consistency, item InsertItem, expected hlc.Timestamp) error
+	Replicate(ctx context.Context, items ...InsertItem) error
+	DropReplicas(ctx context.Context, lo util.ID, hi util.ID) error
+	// Query returns the first item of the index inserted whose key holds the words of the query in order.
+	// Given a key, it returns the item of the key instead. Reconciles the replicas consulted for the consistency level
+	// by picking the item of the latest version, treating the items deleted by a later version as missing.
+	Query(ctx context.Context, index string, query string, key string, consistency Consistency) (InsertItem, error)
+	// QueryReplica queries the replicas held by the node as Query does. Given a key, it looks up the item of the key
+	// instead, returning the version of its tombstone along with it. The item is left empty when only the tombstone is held.
+	QueryReplica(ctx context.Context, index string, query string, key string) (item InsertItem, deleted hlc.Timestamp, err error)
+	// DeleteBatch deletes the items, leaving tombstones in their place, and returns the number of the items that existed.
+	DeleteBatch(ctx context.Context, consistency Consistency, items ...DeleteItem) (int, error)
+	// DeleteReplica deletes the replicas held on behalf of one of the predecessors, leaving tombstones in their place.
+	DeleteReplica(ctx context.Context, items ...DeleteItem) error
+	// Search returns a page of up to limit items of the index whose keys hold the words of the query in order.
+	// An empty query lists all the items of the index. An empty cursor starts from the first page.
+	Search(ctx context.Context, index string, query string, cursor string, limit int) (SearchResult, error)
+	// Rank evaluates the search on the items of the index and returns up to limit of the matches ranking
+	// after the boundary of the search, in rank order.
+	Rank(ctx context.Context, index string, search RankedSearch) ([]RankedItem, error)
+	// Cardinality returns the number of items of the index, as published by the node owning it.
+	Cardinality(ctx context.Context, index string) (int, error)
+}
+
+// Handshake describes the ring a node belongs to and the features it supports.
+// Nodes exchange handshakes before joining the ring.
+type Handshake struct {
+	M               int
+	RingSize        *big.Int
+	Hash            string
+	ProtocolVersion int
+	Capabilities    []string
+	// ReplicationFactor is the number of nodes holding a copy of each item, which the nodes of a ring have to agree on.
+	ReplicationFactor int
+	// Tokenizer is the name of the tokenizer of the node, empty for the peers predating the tokenizers.
+	Tokenizer string
+}
+
+// TokenizerName returns the name of the tokenizer of the peer. The peers predating the tokenizers split on spaces.
+func (hs Handshake) TokenizerName() string {
+	if hs.Tokenizer == "" {
+		return "whitespace"
+	}
+	return hs.Tokenizer
+}
+
+// Capabilities advertised in the handshake.
+const (
+	CapabilityReplication  = "replication"
+	CapabilityConsistency  = "consistency"
+	CapabilityVirtualNodes = "vnodes"
+	CapabilityStreaming    = "streaming"
+	CapabilityFuzzy        = "fuzzy"
+)
+
+func (h Handshake) Supports(capability string) bool {
+	for _, c := range h.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Route is the hop budget and the nodes visited so far by a lookup forwarded through the ring.
+type Route struct {
+	TTL     int
+	Visited []util.ID
+}
+
+// Visit records the node as visited and spends a hop of the budget.
+func (r Route) Visit(id util.ID) Route {
+	visited := make([]util.ID, len(r.Visited), len(r.Visited)+1)
+	copy(visited, r.Visited)
+
+	return Route{TTL: r.TTL - 1, Visited: append(visited, id)}
+}
+
+func (r Route) HasVisited(id util.ID) bool {
+	for _, v := range r.Visited {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Handoff is a batch of items a node offers to another node. The node keeps on owning the items
+// until the receiver confirms the handoff, and offers them again otherwise.
+// The receiver pulls the items with a Transfer.
+type Handoff struct {
+	ID   string
+	Size int
+	// Replicas tells that the items are replicas held by the offering node, which the receiver keeps as replicas as well.
+	Replicas bool
+}
+
+// TransferChunk is a chunk of the items of a handoff, along with the progress of the transfer.
+// The tombstones of the handoff follow the items as the deletions of the items they stand for,
+// and count toward the progress as well.
+type TransferChunk struct {
+	Items   []InsertItem
+	Deletes []DeleteItem
+	Sent    int
+	Total   int
+}
+
+// SearchResult is a page of the items matching a search, ordered by key.
+type SearchResult struct {
+	Items []InsertItem
+	// Cursor continues the search after the last item of the page, empty on the last page.
+	Cursor string
+}
+
+// RankedSearch is a search query ranked by the index node of one of its anchors, which holds every match of the query
+// it anchors. Merging the top matches of every anchor yields the top matches of the query.
+type RankedSearch struct {
+	// Query narrows the items of the index down in the form of Search, before matching them against Expr.
+	Query string
+	Expr  query.Expr
+	// Exact is the expression before turning fuzzy, the matches not matching it are fuzzy. Nil for the exact searches.
+	Exact query.Expr
+	// Words, DF, Keys, AvgLength and Distance are the parameters of the query.Ranker ranking the matches.
+	Words     []string
+	DF        map[string]int
+	Keys      float64
+	AvgLength float64
+	Distance  int
+	// After is the rank of the last match of the previous page, the matches ranking before it are left out.
+	After query.Rank
+	Limit int
+}
+
+// RankedItem is an item matching a ranked search, along with its score.
+type RankedItem struct {
+	Item  InsertItem
+	Score float64
+	// Fuzzy tells that the item matches the search only within the edit distance.
+	Fuzzy bool
+}
+
+// Rank returns the position of the item among the matches of the search.
+func (r RankedItem) Rank() query.Rank {
+	return query.Rank{Fuzzy: r.Fuzzy, Score: r.Score, Key: r.Item.Key}
+}
+
+// InsertRanked inserts the item into the items kept in rank order, keeping up to limit of them.
+func InsertRanked(items []RankedItem, item RankedItem, limit int) []RankedItem {
+	i := sort.Search(len(items), func(i int) bool {
+		return item.Rank().Before(items[i].Rank())
+	})
+	if i >= limit {
+		return items
+	}
+	if len(items) < limit {
+		items = append(items, RankedItem{})
+	}
+	copy(items[i+1:], items[i:])
+	items[i] = item
+
+	return items
 }
 
 type InsertItem struct {
 	Index string
 	Key   string
 	Value string
+	// Mode tells how the write treats an item of the same index and key stored already.
+	Mode WriteMode
+	// Version orders the writes of the item, the write of the latest version wins. Stamped by the node
+	// coordinating the write, or by the owner of the item when zero.
+	Version hlc.Timestamp
+	// ExpiresAt is the deadline of the item, after which the reads leave it out and the nodes holding it evict it.
+	// The item never expires when zero. The deadline is absolute, so that it holds wherever the item is handed over.
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the deadline of the item passed by the time.
+func (i InsertItem) Expired(now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && !now.Before(i.ExpiresAt)
+}
+
+// WriteMode tells how a write treats the items stored already.
+type WriteMode int
+
+const (
+	// Create stores the item only when missing, failing with errs.AlreadyExistsError otherwise.
+	Create WriteMode = iota
+	// Upsert stores the item or replaces the value of the item stored already.
+	Upsert
+	// Update replaces the value of the item stored already, failing with errs.NotFoundError when missing.
+	Update
+)
+
+// DeleteItem identifies the item of the index with the key to delete.
+type DeleteItem struct {
+	Index string
+	Key   string
+	// Version orders the deletion among the writes of the item, as the version of an InsertItem does.
+	// The owner stamps the unversioned deletions after the version of the item it holds.
+	Version hlc.Timestamp
+}
+
+// fuzzyPrefix marks the indexes of the fuzzy variants of the tokens. The tokenizers never yield spaces,
+// so the fuzzy indexes are kept apart from the indexes of the tokens.
+const fuzzyPrefix = "~ "
+
+// FuzzyIndex returns the index holding the keys with a token matching the variant for the fuzzy lookups.
+func FuzzyIndex(variant string) string {
+	return fuzzyPrefix + variant
+}
+
+// IsFuzzyIndex reports whether the index holds the fuzzy variants of the tokens rather than the tokens.
+func IsFuzzyIndex(index string) bool {
+	return strings.HasPrefix(index, fuzzyPrefix)
+}
+
+// prefixPrefix marks the indexes of the leading letters of the tokens, kept apart from the indexes of the tokens
+// the same way as the fuzzy indexes.
+const prefixPrefix = "* "
+
+// PrefixIndex returns the index holding the keys with a token starting with the prefix for the prefix lookups.
+func PrefixIndex(prefix string) string {
+	return prefixPrefix + prefix
+}
+
+// IsPrefixIndex reports whether the index holds the leading letters of the tokens rather than the tokens.
+func IsPrefixIndex(index string) bool {
+	return strings.HasPrefix(index, prefixPrefix)
+}
+
+// Consistency is the number of replicas that have to acknowledge a write or respond to a read.
+type Consistency int
+
+const (
+	One Consistency = iota
+	Quorum
+	All
+)
+
+func ParseConsistency(s string) (Consistency, error) {
+	switch strings.ToUpper(s) {
+	case "", "ONE":
+		return One, nil
+	case "QUORUM":
+		return Quorum, nil
+	case "ALL":
+		return All, nil
+	default:
+		return One, fmt.Errorf("unknown consistency level %q", s)
+	}
+}
+
+func (c Consistency) String() string {
+	switch c {
+	case Quorum:
+		return "QUORUM"
+	case All:
+		return "ALL"
+	default:
+		return "ONE"
+	}
 }
@@ -0,0 +1,130 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/yousuf64/chord-kv/errs"
+)
+
+// encoded is an expression as sent to the index nodes, tagged by its kind. The expressions are sent parsed rather than
+// as queries, as the words of a parsed query would not tokenize to themselves again with every tokenizer.
+type encoded struct {
+	Kind     string    `json:"kind"`
+	Word     string    `json:"word,omitempty"`
+	Distance int       `json:"distance,omitempty"`
+	Words    []string  `json:"words,omitempty"`
+	Exprs    []encoded `json:"exprs,omitempty"`
+}
+
+// Marshal encodes the expression for Unmarshal. A nil expression encodes to nothing.
+func Marshal(expr Expr) ([]byte, error) {
+	if expr == nil {
+		return nil, nil
+	}
+
+	e, err := encode(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(e)
+}
+
+// Unmarshal decodes an expression encoded by Marshal, failing with errs.InvalidQueryError when malformed.
+// Nothing decodes to a nil expression.
+func Unmarshal(data []byte) (Expr, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var e encoded
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.InvalidQueryError, err)
+	}
+
+	return decode(e)
+}
+
+func encode(expr Expr) (encoded, error) {
+	switch e := expr.(type) {
+	case Term:
+		return encoded{Kind: "term", Word: e.Word}, nil
+	case Fuzzy:
+		return encoded{Kind: "fuzzy", Word: e.Word, Distance: e.Distance}, nil
+	case Prefix:
+		return encoded{Kind: "prefix", Word: e.Prefix}, nil
+	case Phrase:
+		return encoded{Kind: "phrase", Words: e.Words}, nil
+	case And:
+		exprs, err := encodeAll(e.Exprs)
+		return encoded{Kind: "and", Exprs: exprs}, err
+	case Or:
+		exprs, err := encodeAll(e.Exprs)
+		return encoded{Kind: "or", Exprs: exprs}, err
+	case Not:
+		exprs, err := encodeAll([]Expr{e.Expr})
+		return encoded{Kind: "not", Exprs: exprs}, err
+	default:
+		return encoded{}, fmt.Errorf("%w: unknown expression %T", errs.InvalidQueryError, expr)
+	}
+}
+
+func encodeAll(exprs []Expr) ([]encoded, error) {
+	encodedExprs := make([]encoded, 0, len(exprs))
+	for _, expr := range exprs {
+		e, err := encode(expr)
+		if err != nil {
+			return nil, err
+		}
+		encodedExprs = append(encodedExprs, e)
+	}
+
+	return encodedExprs, nil
+}
+
+func decode(e encoded) (Expr, error) {
+	switch e.Kind {
+	case "term":
+		return Term{Word: e.Word}, nil
+	case "fuzzy":
+		return Fuzzy{Word: e.Word, Distance: e.Distance}, nil
+	case "prefix":
+		return Prefix{Prefix: e.Word}, nil
+	case "phrase":
+		if len(e.Words) == 0 {
+			return nil, fmt.Errorf("%w: empty phrase", errs.InvalidQueryError)
+		}
+		return Phrase{Words: e.Words}, nil
+	case "and":
+		exprs, err := decodeAll(e.Exprs)
+		return And{Exprs: exprs}, err
+	case "or":
+		exprs, err := decodeAll(e.Exprs)
+		return Or{Exprs: exprs}, err
+	case "not":
+		if len(e.Exprs) != 1 {
+			return nil, fmt.Errorf("%w: negation of %d expressions", errs.InvalidQueryError, len(e.Exprs))
+		}
+		expr, err := decode(e.Exprs[0])
+		return Not{Expr: expr}, err
+	default:
+		return nil, fmt.Errorf("%w: unknown expression %q", errs.InvalidQueryError, e.Kind)
+	}
+}
+
+func decodeAll(encodedExprs []encoded) ([]Expr, error) {
+	if len(encodedExprs) == 0 {
+		return nil, fmt.Errorf("%w: empty operands", errs.InvalidQueryError)
+	}
+
+	exprs := make([]Expr, 0, len(encodedExprs))
+	for _, e := range encodedExprs {
+		expr, err := decode(e)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+
+	return exprs, nil
+}
@@ -0,0 +1,270 @@
+package query
+
+import (
+	"fmt"
+	"github.com/yousuf64/chord-kv/analysis"
+	"github.com/yousuf64/chord-kv/errs"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenPhrase
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lex splits the query into tokens. The operators are recognized only when written in upper case,
+// so that the words "and", "or" and "not" can still be searched for.
+func lex(query string) ([]token, error) {
+	tokens := make([]token, 0)
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == '"':
+			end := strings.IndexByte(query[i+1:], '"')
+			if end == -1 {
+				return nil, fmt.Errorf("%w: unterminated phrase at %d", errs.InvalidQueryError, i)
+			}
+			tokens = append(tokens, token{kind: tokenPhrase, text: query[i+1 : i+1+end], pos: i})
+			i += end + 2
+		default:
+			end := strings.IndexAny(query[i:], " \t()\"")
+			if end == -1 {
+				end = len(query) - i
+			}
+
+			text := query[i : i+end]
+			kind := tokenWord
+			switch text {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: i})
+			i += end
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(query)}), nil
+}
+
+type parser struct {
+	tokens    []token
+	pos       int
+	tokenizer analysis.Tokenizer
+}
+
+// Parse parses a search query into an AST. The grammar, from the lowest to the highest precedence:
+//
+//	or      = and { "OR" and }
+//	and     = unary { [ "AND" ] unary }
+//	unary   = "NOT" unary | primary
+//	primary = "(" or ")" | '"' word { word } '"' | word | word "*"
+//
+// The words and the phrases are tokenized the same way as the keys. A word tokenized into several tokens
+// becomes a phrase, and the words tokenized into none, such as the stop words, are left out of the query.
+// The prefixes are tokenized as well, and have to stay a single token.
+func Parse(query string, tokenizer analysis.Tokenizer) (Expr, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, tokenizer: tokenizer}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.unexpected(tok)
+	}
+	if expr == nil {
+		return nil, fmt.Errorf("%w: no searchable words", errs.InvalidQueryError)
+	}
+
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *parser) or() (Expr, error) {
+	expr, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := appendExpr(nil, expr)
+	for p.peek().kind == tokenOr {
+		p.next()
+		expr, err = p.and()
+		if err != nil {
+			return nil, err
+		}
+		exprs = appendExpr(exprs, expr)
+	}
+
+	switch len(exprs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return exprs[0], nil
+	default:
+		return Or{Exprs: exprs}, nil
+	}
+}
+
+func (p *parser) and() (Expr, error) {
+	expr, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := appendExpr(nil, expr)
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenWord, tokenPhrase, tokenNot, tokenLParen:
+		default:
+			switch len(exprs) {
+			case 0:
+				return nil, nil
+			case 1:
+				return exprs[0], nil
+			default:
+				return And{Exprs: exprs}, nil
+			}
+		}
+
+		expr, err = p.unary()
+		if err != nil {
+			return nil, err
+		}
+		exprs = appendExpr(exprs, expr)
+	}
+}
+
+func (p *parser) unary() (Expr, error) {
+	if p.peek().kind != tokenNot {
+		return p.primary()
+	}
+
+	p.next()
+	expr, err := p.unary()
+	if err != nil || expr == nil {
+		return nil, err
+	}
+
+	return Not{Expr: expr}, nil
+}
+
+func (p *parser) primary() (Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, p.unexpected(closing)
+		}
+		return expr, nil
+	case tokenPhrase:
+		if strings.TrimSpace(tok.text) == "" {
+			return nil, fmt.Errorf("%w: empty phrase at %d", errs.InvalidQueryError, tok.pos)
+		}
+		return p.phrase(tok.text), nil
+	case tokenWord:
+		star := strings.IndexByte(tok.text, '*')
+		switch {
+		case star == -1:
+			return p.phrase(tok.text), nil
+		case star == len(tok.text)-1 && star > 0:
+			return p.prefix(tok.text[:star], tok.pos)
+		default:
+			return nil, fmt.Errorf("%w: wildcard is only allowed at the end of a word, at %d", errs.InvalidQueryError, tok.pos+star)
+		}
+	default:
+		return nil, p.unexpected(tok)
+	}
+}
+
+// phrase tokenizes the text into a phrase, a term when it holds a single token or nothing when it holds none.
+func (p *parser) phrase(text string) Expr {
+	words := p.tokenizer.Tokenize(text)
+	switch len(words) {
+	case 0:
+		return nil
+	case 1:
+		return Term{Word: words[0]}
+	default:
+		return Phrase{Words: words}
+	}
+}
+
+// prefix tokenizes the text of a prefix the same way as the words, so that it matches the tokens of the keys.
+// A prefix tokenized into none, such as a stop word, is only lowercased as it still starts the longer words.
+func (p *parser) prefix(text string, pos int) (Expr, error) {
+	words := p.tokenizer.Tokenize(text)
+	switch len(words) {
+	case 0:
+		return Prefix{Prefix: strings.ToLower(text)}, nil
+	case 1:
+		return Prefix{Prefix: words[0]}, nil
+	default:
+		return nil, fmt.Errorf("%w: prefix of several words at %d", errs.InvalidQueryError, pos)
+	}
+}
+
+// appendExpr appends the expression unless it was left out of the query.
+func appendExpr(exprs []Expr, expr Expr) []Expr {
+	if expr == nil {
+		return exprs
+	}
+	return append(exprs, expr)
+}
+
+func (p *parser) unexpected(tok token) error {
+	if tok.kind == tokenEOF {
+		return fmt.Errorf("%w: unexpected end of the query", errs.InvalidQueryError)
+	}
+
+	return fmt.Errorf("%w: unexpected %q at %d", errs.InvalidQueryError, tok.text, tok.pos)
+}
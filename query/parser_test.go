@@ -0,0 +1,168 @@
+package query
+
+import (
+	"errors"
+	"github.com/yousuf64/chord-kv/analysis"
+	"github.com/yousuf64/chord-kv/errs"
+	"strings"
+	"testing"
+)
+
+func Test_Parse(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "lord", expected: "lord"},
+		{query: "Lord Rings", expected: "(lord AND rings)"},
+		{query: "lord AND rings OR war", expected: "((lord AND rings) OR war)"},
+		{query: "lord AND (rings OR war)", expected: "(lord AND (rings OR war))"},
+		{query: "lord NOT war", expected: "(lord AND NOT war)"},
+		{query: "NOT NOT lord", expected: "NOT NOT lord"},
+		{query: `"lord of the rings" OR lor*`, expected: `("lord of the rings" OR lor*)`},
+		{query: `"lord"`, expected: "lord"},
+		{query: "war and peace", expected: "(war AND and AND peace)"},
+	}
+	for _, c := range cases {
+		expr, err := Parse(c.query, analysis.Whitespace{})
+		if err != nil {
+			t.Fatalf("[%s] parse failed: %v", c.query, err)
+		}
+		if expr.String() != c.expected {
+			t.Fatalf("[%s] expected %s, got %s", c.query, c.expected, expr)
+		}
+	}
+
+	invalid := []string{"", "lord AND", "(lord", "lord)", `"lord of`, `""`, "*", "l*rd", "OR lord", "NOT"}
+	for _, query := range invalid {
+		_, err := Parse(query, analysis.Whitespace{})
+		if !errors.Is(err, errs.InvalidQueryError) {
+			t.Fatalf("[%s] expected invalid query, got %v", query, err)
+		}
+	}
+}
+
+func Test_ParseTokenized(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "The Lord of the Rings", expected: "(lord AND ring)"},
+		{query: `"The Lord of the Rings"`, expected: `"lord ring"`},
+		{query: "the OR hobbit", expected: "hobbit"},
+		{query: "rock-n-roll NOT (the OR a)", expected: `"rock n roll"`},
+		{query: "Walking dogs", expected: "(walk AND dog)"},
+		{query: "Walk*", expected: "walk*"},
+		{query: "Rings*", expected: "ring*"},
+		{query: "The*", expected: "the*"},
+	}
+	for _, c := range cases {
+		expr, err := Parse(c.query, analysis.NewStandard(true))
+		if err != nil {
+			t.Fatalf("[%s] parse failed: %v", c.query, err)
+		}
+		if expr.String() != c.expected {
+			t.Fatalf("[%s] expected %s, got %s", c.query, c.expected, expr)
+		}
+	}
+
+	for _, query := range []string{"the", "NOT the", "(the OR of) AND a", "rock-n*"} {
+		_, err := Parse(query, analysis.NewStandard(true))
+		if !errors.Is(err, errs.InvalidQueryError) {
+			t.Fatalf("[%s] expected invalid query, got %v", query, err)
+		}
+	}
+}
+
+func Test_Fuzz(t *testing.T) {
+	expr, err := Parse(`hobit OR (ring "lord war") NOT wart`, analysis.Whitespace{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fuzzy := Fuzz(expr, 1)
+	expected := `(hobit~1 OR ((ring~1 AND "lord war") AND NOT wart))`
+	if fuzzy.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, fuzzy)
+	}
+
+	cases := []struct {
+		key     string
+		matches bool
+	}{
+		{key: "the hobbit", matches: true},
+		{key: "the hobbits", matches: false},
+		{key: "the rung of lord war", matches: true},
+		{key: "the rung of lord war wart", matches: false},
+		{key: "the rung of lord warts", matches: false},
+		{key: "the rings", matches: false},
+	}
+	for _, c := range cases {
+		if fuzzy.Match(strings.Fields(c.key)) != c.matches {
+			t.Fatalf("[%s] expected match %v for %q", fuzzy, c.matches, c.key)
+		}
+	}
+}
+
+func Test_Match(t *testing.T) {
+	cases := []struct {
+		query   string
+		key     string
+		matches bool
+	}{
+		{query: "rings lord", key: "lord of the rings", matches: true},
+		{query: `"lord of"`, key: "lord of the rings", matches: true},
+		{query: `"lord the"`, key: "lord of the rings", matches: false},
+		{query: `"the rings"`, key: "lord of the rings", matches: true},
+		{query: "lor*", key: "the lord of the flies", matches: true},
+		{query: "lor* NOT flies", key: "the lord of the flies", matches: false},
+		{query: "war OR flies", key: "the lord of the flies", matches: true},
+		{query: "lord NOT (war OR flies)", key: "lord of the rings", matches: true},
+		{query: "lord NOT (war OR flies)", key: "lord of war", matches: false},
+	}
+	for _, c := range cases {
+		expr, err := Parse(c.query, analysis.Whitespace{})
+		if err != nil {
+			t.Fatalf("[%s] parse failed: %v", c.query, err)
+		}
+		if expr.Match(strings.Fields(c.key)) != c.matches {
+			t.Fatalf("[%s] expected match %v for %q", c.query, c.matches, c.key)
+		}
+	}
+}
+
+func Test_Marshal(t *testing.T) {
+	expr, err := Parse(`hobit OR (ring "lord war" lor*) NOT wart`, analysis.Whitespace{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range []Expr{expr, Fuzz(expr, 1)} {
+		data, err := Marshal(e)
+		if err != nil {
+			t.Fatalf("[%s] marshal failed: %v", e, err)
+		}
+
+		decoded, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("[%s] unmarshal failed: %v", e, err)
+		}
+		if decoded.String() != e.String() {
+			t.Fatalf("expected %s, got %s", e, decoded)
+		}
+	}
+
+	if data, _ := Marshal(nil); data != nil {
+		t.Fatalf("expected no encoding of a nil expression, got %s", data)
+	}
+	if decoded, err := Unmarshal(nil); decoded != nil || err != nil {
+		t.Fatalf("expected a nil expression, got %v, %v", decoded, err)
+	}
+
+	for _, data := range []string{"{", `{"kind":"xor"}`, `{"kind":"and"}`, `{"kind":"not","exprs":[]}`, `{"kind":"phrase"}`} {
+		_, err := Unmarshal([]byte(data))
+		if !errors.Is(err, errs.InvalidQueryError) {
+			t.Fatalf("[%s] expected invalid query, got %v", data, err)
+		}
+	}
+}
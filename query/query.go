@@ -0,0 +1,185 @@
+package query
+
+import (
+	"github.com/yousuf64/chord-kv/analysis"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Expr is a node of the AST of a search query, matched against the tokens of a key.
+type Expr interface {
+	Match(words []string) bool
+	String() string
+}
+
+// Term matches the keys holding the word.
+type Term struct {
+	Word string
+}
+
+// Fuzzy matches the keys holding a word within the edit distance of the word. Queries turn fuzzy through Fuzz.
+type Fuzzy struct {
+	Word     string
+	Distance int
+}
+
+// Prefix matches the keys holding a word starting with the prefix, written as `lor*`.
+type Prefix struct {
+	Prefix string
+}
+
+// Phrase matches the keys holding the words next to each other in order, written as `"lord of the rings"`.
+type Phrase struct {
+	Words []string
+}
+
+// And matches the keys matching all of the expressions. Juxtaposed expressions are joined by And as well.
+type And struct {
+	Exprs []Expr
+}
+
+// Or matches the keys matching any of the expressions.
+type Or struct {
+	Exprs []Expr
+}
+
+// Not matches the keys not matching the expression.
+type Not struct {
+	Expr Expr
+}
+
+func (t Term) Match(words []string) bool {
+	for _, word := range words {
+		if word == t.Word {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t Term) String() string {
+	return t.Word
+}
+
+func (f Fuzzy) Match(words []string) bool {
+	for _, word := range words {
+		if analysis.WithinDistance(word, f.Word, f.Distance) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f Fuzzy) String() string {
+	return f.Word + "~" + strconv.Itoa(f.Distance)
+}
+
+func (p Prefix) Match(words []string) bool {
+	for _, word := range words {
+		if strings.HasPrefix(word, p.Prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p Prefix) String() string {
+	return p.Prefix + "*"
+}
+
+func (p Phrase) Match(words []string) bool {
+Words:
+	for i := 0; i+len(p.Words) <= len(words); i++ {
+		for j, word := range p.Words {
+			if words[i+j] != word {
+				continue Words
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+func (p Phrase) String() string {
+	return `"` + strings.Join(p.Words, " ") + `"`
+}
+
+func (a And) Match(words []string) bool {
+	for _, expr := range a.Exprs {
+		if !expr.Match(words) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a And) String() string {
+	return join(a.Exprs, " AND ")
+}
+
+func (o Or) Match(words []string) bool {
+	for _, expr := range o.Exprs {
+		if expr.Match(words) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o Or) String() string {
+	return join(o.Exprs, " OR ")
+}
+
+func (n Not) Match(words []string) bool {
+	return !n.Expr.Match(words)
+}
+
+func (n Not) String() string {
+	return "NOT " + n.Expr.String()
+}
+
+// Fuzz turns the terms of the expression fuzzy, matching the words within the edit distance of them.
+// The words shorter than analysis.MinFuzzyLength, the phrases and the prefixes keep matching exactly.
+func Fuzz(expr Expr, distance int) Expr {
+	switch e := expr.(type) {
+	case Term:
+		if utf8.RuneCountInString(e.Word) < analysis.MinFuzzyLength {
+			return e
+		}
+		return Fuzzy{Word: e.Word, Distance: distance}
+	case And:
+		return And{Exprs: fuzzAll(e.Exprs, distance)}
+	case Or:
+		return Or{Exprs: fuzzAll(e.Exprs, distance)}
+	case Not:
+		// Negations stay exact, a typo in a word to exclude would exclude too much otherwise
+		return e
+	default:
+		return e
+	}
+}
+
+func fuzzAll(exprs []Expr, distance int) []Expr {
+	fuzzed := make([]Expr, 0, len(exprs))
+	for _, expr := range exprs {
+		fuzzed = append(fuzzed, Fuzz(expr, distance))
+	}
+
+	return fuzzed
+}
+
+func join(exprs []Expr, sep string) string {
+	s := make([]string, 0, len(exprs))
+	for _, expr := range exprs {
+		s = append(s, expr.String())
+	}
+
+	return "(" + strings.Join(s, sep) + ")"
+}
@@ -0,0 +1,86 @@
+package query
+
+import (
+	"github.com/yousuf64/chord-kv/analysis"
+	"math"
+)
+
+// BM25 parameters, as commonly tuned.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// fuzzyWeight is the weight of a token within the edit distance of a word of a fuzzy query, relative to the word itself.
+const fuzzyWeight = 0.5
+
+// Ranker scores keys against the words of a query with BM25.
+type Ranker struct {
+	words []string
+	// df holds the number of keys holding each word, as published by the node owning its index.
+	df        map[string]int
+	keys      float64
+	avgLength float64
+	// distance is the edit distance of the tokens counted toward the words of a fuzzy query, 0 for the exact queries.
+	distance int
+}
+
+// NewRanker creates a ranker of the words, given the number of keys in the ring and their average number of words.
+func NewRanker(words []string, df map[string]int, keys float64, avgLength float64, distance int) Ranker {
+	// The estimated number of keys may fall short of the document frequencies of a small ring
+	for _, word := range words {
+		keys = math.Max(keys, float64(df[word]))
+	}
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	return Ranker{words: words, df: df, keys: keys, avgLength: avgLength, distance: distance}
+}
+
+// Score sums up the BM25 weights of the words of the query held by the tokens of a key.
+// Words whose document frequencies are unknown weigh as if they were held by a single key.
+func (r Ranker) Score(keyWords []string) float64 {
+	norm := bm25K1 * (1 - bm25B + bm25B*float64(len(keyWords))/r.avgLength)
+
+	score := 0.0
+	for _, word := range r.words {
+		tf := 0.0
+		for _, keyWord := range keyWords {
+			if keyWord == word {
+				tf++
+			} else if r.distance > 0 && analysis.WithinDistance(keyWord, word, r.distance) {
+				tf += fuzzyWeight
+			}
+		}
+		if tf == 0 {
+			continue
+		}
+
+		df := max(r.df[word], 1)
+		idf := math.Log(1 + (r.keys-float64(df)+0.5)/(float64(df)+0.5))
+		score += idf * tf * (bm25K1 + 1) / (tf + norm)
+	}
+
+	return score
+}
+
+// Rank is the position of a key among the matches of a ranked search.
+type Rank struct {
+	// Fuzzy tells that the key matches the query only within the edit distance.
+	Fuzzy bool
+	Score float64
+	Key   string
+}
+
+// Before reports whether the rank comes before the other one. The exact matches rank before the fuzzy ones,
+// then by descending score and then by key.
+func (r Rank) Before(other Rank) bool {
+	if r.Fuzzy != other.Fuzzy {
+		return !r.Fuzzy
+	}
+	if r.Score != other.Score {
+		return r.Score > other.Score
+	}
+	return r.Key < other.Key
+}
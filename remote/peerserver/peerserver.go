@@ -2,35 +2,137 @@ package peerserver
 
 import (
 	"context"
+	"fmt"
 	"github.com/yousuf64/chord-kv/chord"
+	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/hlc"
 	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/query"
 	"github.com/yousuf64/chord-kv/remote"
 	"github.com/yousuf64/chord-kv/remote/transport"
+	"github.com/yousuf64/chord-kv/util"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"math/big"
 	"os"
 )
 
+// PeerServer serves the Peer RPCs of all the virtual nodes hosted by the process.
 type PeerServer struct {
 	transport.UnimplementedPeerServer
 
-	chord chord.ChordNode
+	primary chord.ChordNode
+	chords  map[util.ID]chord.ChordNode
 }
 
-func New(chord chord.ChordNode) *PeerServer {
-	return &PeerServer{chord: chord}
+func New(primary chord.ChordNode, vnodes ...chord.ChordNode) *PeerServer {
+	chords := map[util.ID]chord.ChordNode{primary.ID(): primary}
+	for _, vnode := range vnodes {
+		chords[vnode.ID()] = vnode
+	}
+
+	return &PeerServer{primary: primary, chords: chords}
+}
+
+// target resolves the virtual node addressed by the request.
+// Requests without a virtual node ID are served by the primary node.
+func (ps *PeerServer) target(ctx context.Context) (chord.ChordNode, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ps.primary, nil
+	}
+
+	values := md.Get(remote.VirtualNodeKey)
+	if len(values) == 0 {
+		return ps.primary, nil
+	}
+
+	id, err := util.ParseID(values[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid virtual node id %q", values[0])
+	}
+
+	ch, ok := ps.chords[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown virtual node %s", id)
+	}
+
+	return ch, nil
+}
+
+func (ps *PeerServer) Handshake(ctx context.Context, request *transport.HandshakeMessage) (*transport.HandshakeMessage, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hs, err := ch.Handshake(ctx, node.Handshake{
+		M:                 int(request.M),
+		RingSize:          new(big.Int).SetBytes(request.RingSize),
+		Hash:              request.Hash,
+		ProtocolVersion:   int(request.ProtocolVersion),
+		Capabilities:      request.Capabilities,
+		ReplicationFactor: int(request.ReplicationFactor),
+		Tokenizer:         request.Tokenizer,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &transport.HandshakeMessage{
+		M:                 int32(hs.M),
+		RingSize:          hs.RingSize.Bytes(),
+		Hash:              hs.Hash,
+		ProtocolVersion:   uint32(hs.ProtocolVersion),
+		Capabilities:      hs.Capabilities,
+		ReplicationFactor: uint32(hs.ReplicationFactor),
+		Tokenizer:         hs.Tokenizer,
+	}, nil
 }
 
 func (ps *PeerServer) FindSuccessor(ctx context.Context, request *transport.FindSuccessorRequest) (*transport.FindSuccessorReply, error) {
-	successor, err := ps.chord.FindSuccessor(ctx, request.Id)
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	route := node.Route{
+		TTL:     int(request.Ttl),
+		Visited: make([]util.ID, 0, len(request.Visited)),
+	}
+	for _, v := range request.Visited {
+		route.Visited = append(route.Visited, util.IDFromBytes(v))
+	}
+
+	successor, err := ch.FindSuccessor(ctx, util.IDFromBytes(request.Id), route)
 	if err != nil {
 		return nil, err
 	}
 
-	return &transport.FindSuccessorReply{Address: successor.Addr()}, nil
+	return &transport.FindSuccessorReply{Address: successor.Addr(), Id: successor.ID().Bytes()}, nil
+}
+
+func (ps *PeerServer) NextHop(ctx context.Context, request *transport.FindSuccessorRequest) (*transport.NextHopReply, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	next, done, err := ch.NextHop(ctx, util.IDFromBytes(request.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	return &transport.NextHopReply{Address: next.Addr(), Id: next.ID().Bytes(), Done: done}, nil
 }
 
 func (ps *PeerServer) SetSuccessor(ctx context.Context, request *transport.SetSuccessorRequest) (*emptypb.Empty, error) {
-	err := ps.chord.SetSuccessor(ctx, remote.NewRemoteNode(request.Address))
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ch.SetSuccessor(ctx, remote.NewVirtualRemoteNode(request.Address, util.IDFromBytes(request.Id)))
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +141,12 @@ func (ps *PeerServer) SetSuccessor(ctx context.Context, request *transport.SetSu
 }
 
 func (ps *PeerServer) SetPredecessor(ctx context.Context, request *transport.SetPredecessorRequest) (*emptypb.Empty, error) {
-	err := ps.chord.SetPredecessor(ctx, remote.NewRemoteNode(request.Address))
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ch.SetPredecessor(ctx, remote.NewVirtualRemoteNode(request.Address, util.IDFromBytes(request.Id)))
 	if err != nil {
 		return nil, err
 	}
@@ -48,45 +155,240 @@ func (ps *PeerServer) SetPredecessor(ctx context.Context, request *transport.Set
 }
 
 func (ps *PeerServer) Notify(ctx context.Context, request *transport.NotifyRequest) (*transport.NotifyReply, error) {
-	insert, err := ps.chord.Notify(ctx, remote.NewRemoteNode(request.Address))
+	ch, err := ps.target(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	reply := &transport.NotifyReply{
-		Items: make([]*transport.InsertItem, 0, len(insert)),
+	handoff, err := ch.Notify(ctx, remote.NewVirtualRemoteNode(request.Address, util.IDFromBytes(request.Id)))
+	if err != nil {
+		return nil, err
 	}
 
-	for _, item := range insert {
-		reply.Items = append(reply.Items, &transport.InsertItem{
-			Index: item.Index,
-			Key:   item.Key,
-			Value: item.Value,
-		})
+	return &transport.NotifyReply{HandoffId: handoff.ID, Size: uint64(handoff.Size)}, nil
+}
+
+func (ps *PeerServer) AcceptHandoff(ctx context.Context, request *transport.AcceptHandoffRequest) (*emptypb.Empty, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return reply, nil
+	from := remote.NewVirtualRemoteNode(request.Address, util.IDFromBytes(request.Id))
+	err = ch.AcceptHandoff(ctx, from, node.Handoff{ID: request.HandoffId, Size: int(request.Size), Replicas: request.Replicas})
+	if err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (ps *PeerServer) Transfer(request *transport.TransferRequest, stream transport.Peer_TransferServer) error {
+	ch, err := ps.target(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	return ch.Transfer(stream.Context(), request.HandoffId, func(chunk node.TransferChunk) error {
+		reply := &transport.TransferChunk{
+			Items:   make([]*transport.InsertItem, 0, len(chunk.Items)),
+			Deletes: make([]*transport.DeleteItem, 0, len(chunk.Deletes)),
+			Sent:    uint64(chunk.Sent),
+			Total:   uint64(chunk.Total),
+		}
+
+		for _, item := range chunk.Items {
+			reply.Items = append(reply.Items, &transport.InsertItem{
+				Index:     item.Index,
+				Key:       item.Key,
+				Value:     item.Value,
+				Version:   uint64(item.Version),
+				ExpiresAt: util.UnixMilli(item.ExpiresAt),
+			})
+		}
+		for _, item := range chunk.Deletes {
+			reply.Deletes = append(reply.Deletes, &transport.DeleteItem{
+				Index:   item.Index,
+				Key:     item.Key,
+				Version: uint64(item.Version),
+			})
+		}
+
+		return stream.Send(reply)
+	})
+}
+
+func (ps *PeerServer) ConfirmHandoff(ctx context.Context, request *transport.ConfirmHandoffRequest) (*emptypb.Empty, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ch.ConfirmHandoff(ctx, request.HandoffId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
 }
 
 func (ps *PeerServer) GetPredecessor(ctx context.Context, _ *emptypb.Empty) (*transport.GetPredecessorReply, error) {
-	predecessor, err := ps.chord.GetPredecessor(ctx)
+	ch, err := ps.target(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &transport.GetPredecessorReply{Address: predecessor.Addr()}, nil
+
+	predecessor, err := ch.GetPredecessor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &transport.GetPredecessorReply{Address: predecessor.Addr(), Id: predecessor.ID().Bytes()}, nil
+}
+
+func (ps *PeerServer) GetSuccessorList(ctx context.Context, _ *emptypb.Empty) (*transport.GetSuccessorListReply, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	successors, err := ch.GetSuccessorList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &transport.GetSuccessorListReply{
+		Successors: make([]*transport.NodeRef, 0, len(successors)),
+	}
+
+	for _, successor := range successors {
+		reply.Successors = append(reply.Successors, &transport.NodeRef{Address: successor.Addr(), Id: successor.ID().Bytes()})
+	}
+
+	return reply, nil
 }
 
 func (ps *PeerServer) Insert(ctx context.Context, request *transport.InsertRequest) (*emptypb.Empty, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	items := make([]node.InsertItem, 0, len(request.Items))
 	for _, item := range request.Items {
 		items = append(items, node.InsertItem{
-			Index: item.Index,
-			Key:   item.Key,
-			Value: item.Value,
+			Index:     item.Index,
+			Key:       item.Key,
+			Value:     item.Value,
+			Mode:      node.WriteMode(item.GetMode()),
+			Version:   hlc.Timestamp(item.GetVersion()),
+			ExpiresAt: util.FromUnixMilli(item.GetExpiresAt()),
 		})
 	}
 
-	err := ps.chord.InsertBatch(ctx, items...)
+	err = ch.InsertBatch(ctx, node.Consistency(request.GetConsistency()), items...)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (ps *PeerServer) CompareAndSwap(ctx context.Context, request *transport.CompareAndSwapRequest) (*emptypb.Empty, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	item := request.GetItem()
+	err = ch.CompareAndSwap(ctx, node.Consistency(request.GetConsistency()), node.InsertItem{
+		Index:     item.GetIndex(),
+		Key:       item.GetKey(),
+		Value:     item.GetValue(),
+		Mode:      node.WriteMode(item.GetMode()),
+		Version:   hlc.Timestamp(item.GetVersion()),
+		ExpiresAt: util.FromUnixMilli(item.GetExpiresAt()),
+	}, hlc.Timestamp(request.GetExpected()))
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (ps *PeerServer) Replicate(ctx context.Context, request *transport.InsertRequest) (*emptypb.Empty, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]node.InsertItem, 0, len(request.Items))
+	for _, item := range request.Items {
+		items = append(items, node.InsertItem{
+			Index:     item.Index,
+			Key:       item.Key,
+			Value:     item.Value,
+			Mode:      node.WriteMode(item.GetMode()),
+			Version:   hlc.Timestamp(item.GetVersion()),
+			ExpiresAt: util.FromUnixMilli(item.GetExpiresAt()),
+		})
+	}
+
+	err = ch.Replicate(ctx, items...)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (ps *PeerServer) Delete(ctx context.Context, request *transport.DeleteRequest) (*transport.DeleteReply, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]node.DeleteItem, 0, len(request.Items))
+	for _, item := range request.Items {
+		items = append(items, node.DeleteItem{
+			Index:   item.Index,
+			Key:     item.Key,
+			Version: hlc.Timestamp(item.GetVersion()),
+		})
+	}
+
+	deleted, err := ch.DeleteBatch(ctx, node.Consistency(request.GetConsistency()), items...)
+	if err != nil {
+		return nil, err
+	}
+	return &transport.DeleteReply{Deleted: uint64(deleted)}, nil
+}
+
+func (ps *PeerServer) DeleteReplica(ctx context.Context, request *transport.DeleteRequest) (*emptypb.Empty, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]node.DeleteItem, 0, len(request.Items))
+	for _, item := range request.Items {
+		items = append(items, node.DeleteItem{
+			Index:   item.Index,
+			Key:     item.Key,
+			Version: hlc.Timestamp(item.GetVersion()),
+		})
+	}
+
+	err = ch.DeleteReplica(ctx, items...)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (ps *PeerServer) DropReplicas(ctx context.Context, request *transport.DropReplicasRequest) (*emptypb.Empty, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ch.DropReplicas(ctx, util.IDFromBytes(request.GetLo()), util.IDFromBytes(request.GetHi()))
 	if err != nil {
 		return nil, err
 	}
@@ -94,12 +396,133 @@ func (ps *PeerServer) Insert(ctx context.Context, request *transport.InsertReque
 }
 
 func (ps *PeerServer) Query(ctx context.Context, request *transport.QueryRequest) (*transport.QueryReply, error) {
-	reply, err := ps.chord.Query(ctx, request.GetIndex(), request.GetQuery())
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := ch.Query(ctx, request.GetIndex(), request.GetQuery(), request.GetKey(), node.Consistency(request.GetConsistency()))
 	if err != nil {
 		return nil, err
 	}
 
-	return &transport.QueryReply{Value: reply}, nil
+	return &transport.QueryReply{Value: reply.Value, Key: reply.Key, Version: uint64(reply.Version), ExpiresAt: util.UnixMilli(reply.ExpiresAt)}, nil
+}
+
+func (ps *PeerServer) QueryReplica(ctx context.Context, request *transport.QueryRequest) (*transport.QueryReply, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, deleted, err := ch.QueryReplica(ctx, request.GetIndex(), request.GetQuery(), request.GetKey())
+	if err != nil {
+		return nil, err
+	}
+
+	return &transport.QueryReply{Value: reply.Value, Key: reply.Key, Version: uint64(reply.Version), ExpiresAt: util.UnixMilli(reply.ExpiresAt), Deleted: uint64(deleted)}, nil
+}
+
+func (ps *PeerServer) Search(ctx context.Context, request *transport.SearchRequest) (*transport.SearchReply, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ch.Search(ctx, request.GetIndex(), request.GetQuery(), request.GetCursor(), int(request.GetLimit()))
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &transport.SearchReply{
+		Items:  make([]*transport.InsertItem, 0, len(result.Items)),
+		Cursor: result.Cursor,
+	}
+
+	for _, item := range result.Items {
+		reply.Items = append(reply.Items, &transport.InsertItem{
+			Index:     item.Index,
+			Key:       item.Key,
+			Value:     item.Value,
+			Version:   uint64(item.Version),
+			ExpiresAt: util.UnixMilli(item.ExpiresAt),
+		})
+	}
+
+	return reply, nil
+}
+
+func (ps *PeerServer) Rank(ctx context.Context, request *transport.RankRequest) (*transport.RankReply, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := query.Unmarshal(request.GetExpr())
+	if err != nil {
+		return nil, err
+	}
+	exact, err := query.Unmarshal(request.GetExact())
+	if err != nil {
+		return nil, err
+	}
+	if expr == nil {
+		return nil, fmt.Errorf("%w: no expression to rank by", errs.InvalidQueryError)
+	}
+
+	df := make(map[string]int, len(request.GetWords()))
+	for i, word := range request.GetWords() {
+		if i < len(request.GetDf()) {
+			df[word] = int(request.GetDf()[i])
+		}
+	}
+
+	items, err := ch.Rank(ctx, request.GetIndex(), node.RankedSearch{
+		Query:     request.GetQuery(),
+		Expr:      expr,
+		Exact:     exact,
+		Words:     request.GetWords(),
+		DF:        df,
+		Keys:      request.GetKeys(),
+		AvgLength: request.GetAvgLength(),
+		Distance:  int(request.GetDistance()),
+		After:     query.Rank{Fuzzy: request.GetAfterFuzzy(), Score: request.GetAfterScore(), Key: request.GetAfterKey()},
+		Limit:     int(request.GetLimit()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &transport.RankReply{Items: make([]*transport.RankedItem, 0, len(items))}
+	for _, it := range items {
+		reply.Items = append(reply.Items, &transport.RankedItem{
+			Item: &transport.InsertItem{
+				Index:     it.Item.Index,
+				Key:       it.Item.Key,
+				Value:     it.Item.Value,
+				Version:   uint64(it.Item.Version),
+				ExpiresAt: util.UnixMilli(it.Item.ExpiresAt),
+			},
+			Score: it.Score,
+			Fuzzy: it.Fuzzy,
+		})
+	}
+
+	return reply, nil
+}
+
+func (ps *PeerServer) Cardinality(ctx context.Context, request *transport.CardinalityRequest) (*transport.CardinalityReply, error) {
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cardinality, err := ch.Cardinality(ctx, request.GetIndex())
+	if err != nil {
+		return nil, err
+	}
+
+	return &transport.CardinalityReply{Cardinality: uint64(cardinality)}, nil
 }
 
 func (ps *PeerServer) Leave(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
@@ -124,7 +547,12 @@ func (ps *PeerServer) Leave(ctx context.Context, _ *emptypb.Empty) (*emptypb.Emp
 }
 
 func (ps *PeerServer) Healthz(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
-	err := ps.chord.Healthz(ctx)
+	ch, err := ps.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ch.Healthz(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -5,25 +5,56 @@ import (
 	"errors"
 	"fmt"
 	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/hlc"
 	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/query"
 	"github.com/yousuf64/chord-kv/remote/transport"
 	"github.com/yousuf64/chord-kv/util"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel/propagation"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
 )
 
+// VirtualNodeKey is the metadata key carrying the ID of the virtual node targeted by a Peer RPC.
+const VirtualNodeKey = "chord-vnode"
+
+var clients = sync.Map{} // Address -> transport.PeerClient
+
 type RemoteNode struct {
-	id     uint64
+	id     util.ID
 	addr   string
 	client transport.PeerClient
 }
 
-func NewRemoteNode(addr string) *RemoteNode {
-	client, err := grpc.NewClient(addr,
+// NewRemoteNode refers to the first virtual node hosted at the address, which takes the ID of the address itself.
+func NewRemoteNode(addr string, space util.Space) *RemoteNode {
+	return NewVirtualRemoteNode(addr, space.Hash(addr))
+}
+
+// NewVirtualRemoteNode refers to the virtual node with the given ID hosted at the address.
+func NewVirtualRemoteNode(addr string, id util.ID) *RemoteNode {
+	return &RemoteNode{
+		id:     id,
+		addr:   addr,
+		client: peerClient(addr),
+	}
+}
+
+// peerClient returns the client of the address, sharing a single connection among the virtual nodes of a host.
+func peerClient(addr string) transport.PeerClient {
+	if client, ok := clients.Load(addr); ok {
+		return client.(transport.PeerClient)
+	}
+
+	conn, err := grpc.NewClient(addr,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithStatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithPropagators(propagation.TraceContext{}))),
 	)
@@ -32,62 +63,288 @@ func NewRemoteNode(addr string) *RemoteNode {
 		panic(err)
 	}
 
-	return &RemoteNode{
-		id:     util.Hash(addr),
-		addr:   addr,
-		client: transport.NewPeerClient(client),
+	client, loaded := clients.LoadOrStore(addr, transport.NewPeerClient(conn))
+	if loaded {
+		_ = conn.Close()
+	}
+
+	return client.(transport.PeerClient)
+}
+
+// target attaches the ID of the virtual node to the outgoing request.
+func (r *RemoteNode) target(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, VirtualNodeKey, r.id.String())
+}
+
+// fromStatus maps the error of a Peer RPC back to the known error its message starts with, keeping the details
+// following it, so that the wrapped errors the peers fail with match errors.Is as well. The rest keep their messages.
+func fromStatus(err error, known ...error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	for _, k := range known {
+		if st.Message() == k.Error() {
+			return k
+		}
+		if strings.HasPrefix(st.Message(), k.Error()) {
+			return fmt.Errorf("%w%s", k, strings.TrimPrefix(st.Message(), k.Error()))
+		}
 	}
+
+	return errors.New(st.Message())
 }
 
-func (r *RemoteNode) InsertBatch(ctx context.Context, items ...node.InsertItem) error {
+func (r *RemoteNode) InsertBatch(ctx context.Context, consistency node.Consistency, items ...node.InsertItem) error {
+	req := &transport.InsertRequest{
+		Items:       make([]*transport.InsertItem, 0, len(items)),
+		Consistency: transport.Consistency(consistency),
+	}
+
+	for _, item := range items {
+		req.Items = append(req.Items, &transport.InsertItem{
+			Index:     item.Index,
+			Key:       item.Key,
+			Value:     item.Value,
+			Mode:      transport.WriteMode(item.Mode),
+			Version:   uint64(item.Version),
+			ExpiresAt: util.UnixMilli(item.ExpiresAt),
+		})
+	}
+
+	_, err := r.client.Insert(r.target(ctx), req)
+	if err != nil {
+		return fromStatus(err, errs.AlreadyExistsError, errs.NotFoundError, errs.InsufficientReplicasError)
+	}
+	return nil
+}
+
+func (r *RemoteNode) CompareAndSwap(ctx context.Context, consistency node.Consistency, item node.InsertItem, expected hlc.Timestamp) error {
+	_, err := r.client.CompareAndSwap(r.target(ctx), &transport.CompareAndSwapRequest{
+		Item: &transport.InsertItem{
+			Index:     item.Index,
+			Key:       item.Key,
+			Value:     item.Value,
+			Mode:      transport.WriteMode(item.Mode),
+			Version:   uint64(item.Version),
+			ExpiresAt: util.UnixMilli(item.ExpiresAt),
+		},
+		Expected:    uint64(expected),
+		Consistency: transport.Consistency(consistency),
+	})
+	if err != nil {
+		return fromStatus(err, errs.VersionMismatchError, errs.NotFoundError, errs.InsufficientReplicasError)
+	}
+	return nil
+}
+
+func (r *RemoteNode) Replicate(ctx context.Context, items ...node.InsertItem) error {
 	req := &transport.InsertRequest{
 		Items: make([]*transport.InsertItem, 0, len(items)),
 	}
 
 	for _, item := range items {
 		req.Items = append(req.Items, &transport.InsertItem{
-			Index: item.Index,
-			Key:   item.Key,
-			Value: item.Value,
+			Index:     item.Index,
+			Key:       item.Key,
+			Value:     item.Value,
+			Mode:      transport.WriteMode(item.Mode),
+			Version:   uint64(item.Version),
+			ExpiresAt: util.UnixMilli(item.ExpiresAt),
 		})
 	}
 
-	_, err := r.client.Insert(ctx, req)
+	_, err := r.client.Replicate(r.target(ctx), req)
 	if err != nil {
-		st, _ := status.FromError(err)
-		if st != nil {
-			err = fmt.Errorf(st.Message())
-			if err.Error() == errs.AlreadyExistsError.Error() {
-				err = errs.AlreadyExistsError
-			}
-		}
+		return err
 	}
+
 	return nil
 }
 
-func (r *RemoteNode) Query(ctx context.Context, index string, query string) (string, error) {
+func (r *RemoteNode) DeleteBatch(ctx context.Context, consistency node.Consistency, items ...node.DeleteItem) (int, error) {
+	req := &transport.DeleteRequest{
+		Items:       make([]*transport.DeleteItem, 0, len(items)),
+		Consistency: transport.Consistency(consistency),
+	}
+
+	for _, item := range items {
+		req.Items = append(req.Items, &transport.DeleteItem{
+			Index:   item.Index,
+			Key:     item.Key,
+			Version: uint64(item.Version),
+		})
+	}
+
+	reply, err := r.client.Delete(r.target(ctx), req)
+	if err != nil {
+		return 0, fromStatus(err, errs.InsufficientReplicasError)
+	}
+
+	return int(reply.Deleted), nil
+}
+
+func (r *RemoteNode) DeleteReplica(ctx context.Context, items ...node.DeleteItem) error {
+	req := &transport.DeleteRequest{
+		Items: make([]*transport.DeleteItem, 0, len(items)),
+	}
+
+	for _, item := range items {
+		req.Items = append(req.Items, &transport.DeleteItem{
+			Index:   item.Index,
+			Key:     item.Key,
+			Version: uint64(item.Version),
+		})
+	}
+
+	_, err := r.client.DeleteReplica(r.target(ctx), req)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *RemoteNode) DropReplicas(ctx context.Context, lo util.ID, hi util.ID) error {
+	_, err := r.client.DropReplicas(r.target(ctx), &transport.DropReplicasRequest{Lo: lo.Bytes(), Hi: hi.Bytes()})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *RemoteNode) Query(ctx context.Context, index string, query string, key string, consistency node.Consistency) (node.InsertItem, error) {
+	req := &transport.QueryRequest{
+		Index:       index,
+		Query:       query,
+		Consistency: transport.Consistency(consistency),
+		Key:         key,
+	}
+
+	reply, err := r.client.Query(r.target(ctx), req)
+	if err != nil {
+		return node.InsertItem{}, fromStatus(err, errs.NotFoundError, errs.InsufficientReplicasError)
+	}
+
+	return node.InsertItem{Index: index, Key: reply.Key, Value: reply.Value, Version: hlc.Timestamp(reply.Version), ExpiresAt: util.FromUnixMilli(reply.ExpiresAt)}, nil
+}
+
+func (r *RemoteNode) QueryReplica(ctx context.Context, index string, query string, key string) (node.InsertItem, hlc.Timestamp, error) {
 	req := &transport.QueryRequest{
 		Index: index,
 		Query: query,
+		Key:   key,
 	}
 
-	reply, err := r.client.Query(ctx, req)
+	reply, err := r.client.QueryReplica(r.target(ctx), req)
 	if err != nil {
-		st, _ := status.FromError(err)
-		if st != nil {
-			err = fmt.Errorf(st.Message())
-			if err.Error() == errs.NotFoundError.Error() {
-				err = errs.NotFoundError
-			}
-		}
+		return node.InsertItem{}, 0, fromStatus(err, errs.NotFoundError)
+	}
 
-		return "", err
+	if reply.Key == "" {
+		return node.InsertItem{}, hlc.Timestamp(reply.Deleted), nil
 	}
 
-	return reply.Value, nil
+	return node.InsertItem{Index: index, Key: reply.Key, Value: reply.Value, Version: hlc.Timestamp(reply.Version), ExpiresAt: util.FromUnixMilli(reply.ExpiresAt)}, hlc.Timestamp(reply.Deleted), nil
 }
 
-func (r *RemoteNode) ID() uint64 {
+func (r *RemoteNode) Search(ctx context.Context, index string, query string, cursor string, limit int) (node.SearchResult, error) {
+	req := &transport.SearchRequest{
+		Index:  index,
+		Query:  query,
+		Cursor: cursor,
+		Limit:  uint32(max(limit, 0)),
+	}
+
+	reply, err := r.client.Search(r.target(ctx), req)
+	if err != nil {
+		return node.SearchResult{}, fromStatus(err, errs.InvalidCursorError)
+	}
+
+	result := node.SearchResult{
+		Items:  make([]node.InsertItem, 0, len(reply.Items)),
+		Cursor: reply.Cursor,
+	}
+	for _, item := range reply.Items {
+		result.Items = append(result.Items, node.InsertItem{
+			Index:     item.Index,
+			Key:       item.Key,
+			Value:     item.Value,
+			Version:   hlc.Timestamp(item.GetVersion()),
+			ExpiresAt: util.FromUnixMilli(item.GetExpiresAt()),
+		})
+	}
+
+	return result, nil
+}
+
+func (r *RemoteNode) Rank(ctx context.Context, index string, search node.RankedSearch) ([]node.RankedItem, error) {
+	expr, err := query.Marshal(search.Expr)
+	if err != nil {
+		return nil, err
+	}
+	exact, err := query.Marshal(search.Exact)
+	if err != nil {
+		return nil, err
+	}
+
+	df := make([]uint64, 0, len(search.Words))
+	for _, word := range search.Words {
+		df = append(df, uint64(search.DF[word]))
+	}
+
+	req := &transport.RankRequest{
+		Index:      index,
+		Query:      search.Query,
+		Expr:       expr,
+		Exact:      exact,
+		Words:      search.Words,
+		Df:         df,
+		Keys:       search.Keys,
+		AvgLength:  search.AvgLength,
+		Distance:   uint32(search.Distance),
+		AfterFuzzy: search.After.Fuzzy,
+		AfterScore: search.After.Score,
+		AfterKey:   search.After.Key,
+		Limit:      uint32(max(search.Limit, 0)),
+	}
+
+	reply, err := r.client.Rank(r.target(ctx), req)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]node.RankedItem, 0, len(reply.Items))
+	for _, it := range reply.Items {
+		item := it.GetItem()
+		items = append(items, node.RankedItem{
+			Item: node.InsertItem{
+				Index:     item.GetIndex(),
+				Key:       item.GetKey(),
+				Value:     item.GetValue(),
+				Version:   hlc.Timestamp(item.GetVersion()),
+				ExpiresAt: util.FromUnixMilli(item.GetExpiresAt()),
+			},
+			Score: it.Score,
+			Fuzzy: it.Fuzzy,
+		})
+	}
+
+	return items, nil
+}
+
+func (r *RemoteNode) Cardinality(ctx context.Context, index string) (int, error) {
+	reply, err := r.client.Cardinality(r.target(ctx), &transport.CardinalityRequest{Index: index})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(reply.Cardinality), nil
+}
+
+func (r *RemoteNode) ID() util.ID {
 	return r.id
 }
 
@@ -95,21 +352,68 @@ func (r *RemoteNode) Addr() string {
 	return r.addr
 }
 
-func (r *RemoteNode) FindSuccessor(ctx context.Context, id uint64) (node.Node, error) {
-	reply, err := r.client.FindSuccessor(ctx, &transport.FindSuccessorRequest{Id: id})
+func (r *RemoteNode) Handshake(ctx context.Context, hs node.Handshake) (node.Handshake, error) {
+	reply, err := r.client.Handshake(r.target(ctx), &transport.HandshakeMessage{
+		M:                 int32(hs.M),
+		RingSize:          hs.RingSize.Bytes(),
+		Hash:              hs.Hash,
+		ProtocolVersion:   uint32(hs.ProtocolVersion),
+		Capabilities:      hs.Capabilities,
+		ReplicationFactor: uint32(hs.ReplicationFactor),
+		Tokenizer:         hs.Tokenizer,
+	})
 	if err != nil {
-		return nil, err
+		return node.Handshake{}, fromStatus(err, errs.IncompatiblePeerError)
+	}
+
+	return node.Handshake{
+		M:                 int(reply.M),
+		RingSize:          new(big.Int).SetBytes(reply.RingSize),
+		Hash:              reply.Hash,
+		ProtocolVersion:   int(reply.ProtocolVersion),
+		Capabilities:      reply.Capabilities,
+		ReplicationFactor: int(reply.ReplicationFactor),
+		Tokenizer:         reply.Tokenizer,
+	}, nil
+}
+
+func (r *RemoteNode) FindSuccessor(ctx context.Context, id util.ID, route node.Route) (node.Node, error) {
+	req := &transport.FindSuccessorRequest{
+		Id:      id.Bytes(),
+		Ttl:     uint32(max(route.TTL, 0)),
+		Visited: make([][]byte, 0, len(route.Visited)),
+	}
+	for _, v := range route.Visited {
+		req.Visited = append(req.Visited, v.Bytes())
+	}
+
+	reply, err := r.client.FindSuccessor(r.target(ctx), req)
+	if err != nil {
+		return nil, fromStatus(err, errs.LookupLoopError, errs.LookupHopLimitError)
 	}
 
 	if reply.Address == "" {
 		return nil, errors.New("not found")
 	}
 
-	return NewRemoteNode(reply.Address), nil
+	return NewVirtualRemoteNode(reply.Address, util.IDFromBytes(reply.Id)), nil
+}
+
+func (r *RemoteNode) NextHop(ctx context.Context, id util.ID) (node.Node, bool, error) {
+	reply, err := r.client.NextHop(r.target(ctx), &transport.FindSuccessorRequest{Id: id.Bytes()})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if reply.Address == "" {
+		return nil, false, errors.New("not found")
+	}
+
+	return NewVirtualRemoteNode(reply.Address, util.IDFromBytes(reply.Id)), reply.Done, nil
 }
 
 func (r *RemoteNode) SetSuccessor(ctx context.Context, successor node.Node) error {
-	_, err := r.client.SetSuccessor(ctx, &transport.SetSuccessorRequest{Address: successor.Addr()})
+	_, err := r.client.SetSuccessor(r.target(ctx), &transport.SetSuccessorRequest{Address: successor.Addr(), Id: successor.ID().Bytes()})
 	if err != nil {
 		return err
 	}
@@ -118,7 +422,7 @@ func (r *RemoteNode) SetSuccessor(ctx context.Context, successor node.Node) erro
 }
 
 func (r *RemoteNode) SetPredecessor(ctx context.Context, predecessor node.Node) error {
-	_, err := r.client.SetPredecessor(ctx, &transport.SetPredecessorRequest{Address: predecessor.Addr()})
+	_, err := r.client.SetPredecessor(r.target(ctx), &transport.SetPredecessorRequest{Address: predecessor.Addr(), Id: predecessor.ID().Bytes()})
 	if err != nil {
 		return err
 	}
@@ -126,35 +430,108 @@ func (r *RemoteNode) SetPredecessor(ctx context.Context, predecessor node.Node)
 	return nil
 }
 
-func (r *RemoteNode) Notify(ctx context.Context, p node.Node) ([]node.InsertItem, error) {
-	reply, err := r.client.Notify(ctx, &transport.NotifyRequest{Address: p.Addr()})
+func (r *RemoteNode) Notify(ctx context.Context, p node.Node) (node.Handoff, error) {
+	reply, err := r.client.Notify(r.target(ctx), &transport.NotifyRequest{Address: p.Addr(), Id: p.ID().Bytes()})
 	if err != nil {
-		return nil, err
+		return node.Handoff{}, err
 	}
 
-	insert := make([]node.InsertItem, 0, len(reply.Items))
-	for _, item := range reply.Items {
-		insert = append(insert, node.InsertItem{
-			Index: item.Index,
-			Key:   item.Key,
-			Value: item.Value,
-		})
+	return node.Handoff{ID: reply.HandoffId, Size: int(reply.Size)}, nil
+}
+
+func (r *RemoteNode) AcceptHandoff(ctx context.Context, from node.Node, handoff node.Handoff) error {
+	_, err := r.client.AcceptHandoff(r.target(ctx), &transport.AcceptHandoffRequest{
+		Address:   from.Addr(),
+		Id:        from.ID().Bytes(),
+		HandoffId: handoff.ID,
+		Size:      uint64(handoff.Size),
+		Replicas:  handoff.Replicas,
+	})
+	if err != nil {
+		return err
 	}
 
-	return insert, nil
+	return nil
+}
+
+func (r *RemoteNode) Transfer(ctx context.Context, id string, send func(chunk node.TransferChunk) error) error {
+	stream, err := r.client.Transfer(r.target(ctx), &transport.TransferRequest{HandoffId: id})
+	if err != nil {
+		return err
+	}
+
+	for {
+		reply, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		chunk := node.TransferChunk{
+			Items: make([]node.InsertItem, 0, len(reply.Items)),
+			Sent:  int(reply.Sent),
+			Total: int(reply.Total),
+		}
+		for _, item := range reply.Items {
+			chunk.Items = append(chunk.Items, node.InsertItem{
+				Index:     item.Index,
+				Key:       item.Key,
+				Value:     item.Value,
+				Version:   hlc.Timestamp(item.GetVersion()),
+				ExpiresAt: util.FromUnixMilli(item.GetExpiresAt()),
+			})
+		}
+		for _, item := range reply.Deletes {
+			chunk.Deletes = append(chunk.Deletes, node.DeleteItem{
+				Index:   item.Index,
+				Key:     item.Key,
+				Version: hlc.Timestamp(item.GetVersion()),
+			})
+		}
+
+		err = send(chunk)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *RemoteNode) ConfirmHandoff(ctx context.Context, id string) error {
+	_, err := r.client.ConfirmHandoff(r.target(ctx), &transport.ConfirmHandoffRequest{HandoffId: id})
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (r *RemoteNode) GetPredecessor(ctx context.Context) (node.Node, error) {
-	reply, err := r.client.GetPredecessor(ctx, &emptypb.Empty{})
+	reply, err := r.client.GetPredecessor(r.target(ctx), &emptypb.Empty{})
 	if err != nil {
 		st, _ := status.FromError(err)
 		return nil, fmt.Errorf(st.Message())
 	}
-	return NewRemoteNode(reply.Address), nil
+	return NewVirtualRemoteNode(reply.Address, util.IDFromBytes(reply.Id)), nil
+}
+
+func (r *RemoteNode) GetSuccessorList(ctx context.Context) ([]node.Node, error) {
+	reply, err := r.client.GetSuccessorList(r.target(ctx), &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	successors := make([]node.Node, 0, len(reply.Successors))
+	for _, successor := range reply.Successors {
+		successors = append(successors, NewVirtualRemoteNode(successor.Address, util.IDFromBytes(successor.Id)))
+	}
+
+	return successors, nil
 }
 
 func (r *RemoteNode) Healthz(ctx context.Context) error {
-	_, err := r.client.Healthz(ctx, &emptypb.Empty{})
+	_, err := r.client.Healthz(r.target(ctx), &emptypb.Empty{})
 	if err != nil {
 		return err
 	}
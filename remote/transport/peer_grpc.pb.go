@@ -23,15 +23,30 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type PeerClient interface {
+	Handshake(ctx context.Context, in *HandshakeMessage, opts ...grpc.CallOption) (*HandshakeMessage, error)
 	FindSuccessor(ctx context.Context, in *FindSuccessorRequest, opts ...grpc.CallOption) (*FindSuccessorReply, error)
+	NextHop(ctx context.Context, in *FindSuccessorRequest, opts ...grpc.CallOption) (*NextHopReply, error)
 	SetSuccessor(ctx context.Context, in *SetSuccessorRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	SetPredecessor(ctx context.Context, in *SetPredecessorRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	Notify(ctx context.Context, in *NotifyRequest, opts ...grpc.CallOption) (*NotifyReply, error)
+	AcceptHandoff(ctx context.Context, in *AcceptHandoffRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Transfer(ctx context.Context, in *TransferRequest, opts ...grpc.CallOption) (Peer_TransferClient, error)
+	ConfirmHandoff(ctx context.Context, in *ConfirmHandoffRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	GetPredecessor(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetPredecessorReply, error)
+	GetSuccessorList(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetSuccessorListReply, error)
 	Leave(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	Healthz(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	Insert(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Replicate(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryReply, error)
+	QueryReplica(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryReply, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchReply, error)
+	Cardinality(ctx context.Context, in *CardinalityRequest, opts ...grpc.CallOption) (*CardinalityReply, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteReply, error)
+	DeleteReplica(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Rank(ctx context.Context, in *RankRequest, opts ...grpc.CallOption) (*RankReply, error)
+	DropReplicas(ctx context.Context, in *DropReplicasRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type peerClient struct {
@@ -42,6 +57,15 @@ func NewPeerClient(cc grpc.ClientConnInterface) PeerClient {
 	return &peerClient{cc}
 }
 
+func (c *peerClient) Handshake(ctx context.Context, in *HandshakeMessage, opts ...grpc.CallOption) (*HandshakeMessage, error) {
+	out := new(HandshakeMessage)
+	err := c.cc.Invoke(ctx, "/Peer/Handshake", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *peerClient) FindSuccessor(ctx context.Context, in *FindSuccessorRequest, opts ...grpc.CallOption) (*FindSuccessorReply, error) {
 	out := new(FindSuccessorReply)
 	err := c.cc.Invoke(ctx, "/Peer/FindSuccessor", in, out, opts...)
@@ -51,6 +75,15 @@ func (c *peerClient) FindSuccessor(ctx context.Context, in *FindSuccessorRequest
 	return out, nil
 }
 
+func (c *peerClient) NextHop(ctx context.Context, in *FindSuccessorRequest, opts ...grpc.CallOption) (*NextHopReply, error) {
+	out := new(NextHopReply)
+	err := c.cc.Invoke(ctx, "/Peer/NextHop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *peerClient) SetSuccessor(ctx context.Context, in *SetSuccessorRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, "/Peer/SetSuccessor", in, out, opts...)
@@ -78,6 +111,56 @@ func (c *peerClient) Notify(ctx context.Context, in *NotifyRequest, opts ...grpc
 	return out, nil
 }
 
+func (c *peerClient) AcceptHandoff(ctx context.Context, in *AcceptHandoffRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/Peer/AcceptHandoff", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *peerClient) Transfer(ctx context.Context, in *TransferRequest, opts ...grpc.CallOption) (Peer_TransferClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Peer_ServiceDesc.Streams[0], "/Peer/Transfer", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &peerTransferClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Peer_TransferClient interface {
+	Recv() (*TransferChunk, error)
+	grpc.ClientStream
+}
+
+type peerTransferClient struct {
+	grpc.ClientStream
+}
+
+func (x *peerTransferClient) Recv() (*TransferChunk, error) {
+	m := new(TransferChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *peerClient) ConfirmHandoff(ctx context.Context, in *ConfirmHandoffRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/Peer/ConfirmHandoff", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *peerClient) GetPredecessor(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetPredecessorReply, error) {
 	out := new(GetPredecessorReply)
 	err := c.cc.Invoke(ctx, "/Peer/GetPredecessor", in, out, opts...)
@@ -87,6 +170,15 @@ func (c *peerClient) GetPredecessor(ctx context.Context, in *emptypb.Empty, opts
 	return out, nil
 }
 
+func (c *peerClient) GetSuccessorList(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetSuccessorListReply, error) {
+	out := new(GetSuccessorListReply)
+	err := c.cc.Invoke(ctx, "/Peer/GetSuccessorList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *peerClient) Leave(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, "/Peer/Leave", in, out, opts...)
@@ -114,6 +206,24 @@ func (c *peerClient) Insert(ctx context.Context, in *InsertRequest, opts ...grpc
 	return out, nil
 }
 
+func (c *peerClient) CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/Peer/CompareAndSwap", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *peerClient) Replicate(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/Peer/Replicate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *peerClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryReply, error) {
 	out := new(QueryReply)
 	err := c.cc.Invoke(ctx, "/Peer/Query", in, out, opts...)
@@ -123,19 +233,97 @@ func (c *peerClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.C
 	return out, nil
 }
 
+func (c *peerClient) QueryReplica(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryReply, error) {
+	out := new(QueryReply)
+	err := c.cc.Invoke(ctx, "/Peer/QueryReplica", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *peerClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchReply, error) {
+	out := new(SearchReply)
+	err := c.cc.Invoke(ctx, "/Peer/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *peerClient) Cardinality(ctx context.Context, in *CardinalityRequest, opts ...grpc.CallOption) (*CardinalityReply, error) {
+	out := new(CardinalityReply)
+	err := c.cc.Invoke(ctx, "/Peer/Cardinality", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *peerClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteReply, error) {
+	out := new(DeleteReply)
+	err := c.cc.Invoke(ctx, "/Peer/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *peerClient) DeleteReplica(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/Peer/DeleteReplica", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *peerClient) Rank(ctx context.Context, in *RankRequest, opts ...grpc.CallOption) (*RankReply, error) {
+	out := new(RankReply)
+	err := c.cc.Invoke(ctx, "/Peer/Rank", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *peerClient) DropReplicas(ctx context.Context, in *DropReplicasRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/Peer/DropReplicas", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // PeerServer is the server API for Peer service.
 // All implementations must embed UnimplementedPeerServer
 // for forward compatibility
 type PeerServer interface {
+	Handshake(context.Context, *HandshakeMessage) (*HandshakeMessage, error)
 	FindSuccessor(context.Context, *FindSuccessorRequest) (*FindSuccessorReply, error)
+	NextHop(context.Context, *FindSuccessorRequest) (*NextHopReply, error)
 	SetSuccessor(context.Context, *SetSuccessorRequest) (*emptypb.Empty, error)
 	SetPredecessor(context.Context, *SetPredecessorRequest) (*emptypb.Empty, error)
 	Notify(context.Context, *NotifyRequest) (*NotifyReply, error)
+	AcceptHandoff(context.Context, *AcceptHandoffRequest) (*emptypb.Empty, error)
+	Transfer(*TransferRequest, Peer_TransferServer) error
+	ConfirmHandoff(context.Context, *ConfirmHandoffRequest) (*emptypb.Empty, error)
 	GetPredecessor(context.Context, *emptypb.Empty) (*GetPredecessorReply, error)
+	GetSuccessorList(context.Context, *emptypb.Empty) (*GetSuccessorListReply, error)
 	Leave(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
 	Healthz(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
 	Insert(context.Context, *InsertRequest) (*emptypb.Empty, error)
+	CompareAndSwap(context.Context, *CompareAndSwapRequest) (*emptypb.Empty, error)
+	Replicate(context.Context, *InsertRequest) (*emptypb.Empty, error)
 	Query(context.Context, *QueryRequest) (*QueryReply, error)
+	QueryReplica(context.Context, *QueryRequest) (*QueryReply, error)
+	Search(context.Context, *SearchRequest) (*SearchReply, error)
+	Cardinality(context.Context, *CardinalityRequest) (*CardinalityReply, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteReply, error)
+	DeleteReplica(context.Context, *DeleteRequest) (*emptypb.Empty, error)
+	Rank(context.Context, *RankRequest) (*RankReply, error)
+	DropReplicas(context.Context, *DropReplicasRequest) (*emptypb.Empty, error)
 	mustEmbedUnimplementedPeerServer()
 }
 
@@ -143,9 +331,15 @@ type PeerServer interface {
 type UnimplementedPeerServer struct {
 }
 
+func (UnimplementedPeerServer) Handshake(context.Context, *HandshakeMessage) (*HandshakeMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Handshake not implemented")
+}
 func (UnimplementedPeerServer) FindSuccessor(context.Context, *FindSuccessorRequest) (*FindSuccessorReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FindSuccessor not implemented")
 }
+func (UnimplementedPeerServer) NextHop(context.Context, *FindSuccessorRequest) (*NextHopReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NextHop not implemented")
+}
 func (UnimplementedPeerServer) SetSuccessor(context.Context, *SetSuccessorRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetSuccessor not implemented")
 }
@@ -155,9 +349,21 @@ func (UnimplementedPeerServer) SetPredecessor(context.Context, *SetPredecessorRe
 func (UnimplementedPeerServer) Notify(context.Context, *NotifyRequest) (*NotifyReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Notify not implemented")
 }
+func (UnimplementedPeerServer) AcceptHandoff(context.Context, *AcceptHandoffRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptHandoff not implemented")
+}
+func (UnimplementedPeerServer) Transfer(*TransferRequest, Peer_TransferServer) error {
+	return status.Errorf(codes.Unimplemented, "method Transfer not implemented")
+}
+func (UnimplementedPeerServer) ConfirmHandoff(context.Context, *ConfirmHandoffRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmHandoff not implemented")
+}
 func (UnimplementedPeerServer) GetPredecessor(context.Context, *emptypb.Empty) (*GetPredecessorReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetPredecessor not implemented")
 }
+func (UnimplementedPeerServer) GetSuccessorList(context.Context, *emptypb.Empty) (*GetSuccessorListReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSuccessorList not implemented")
+}
 func (UnimplementedPeerServer) Leave(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Leave not implemented")
 }
@@ -167,9 +373,36 @@ func (UnimplementedPeerServer) Healthz(context.Context, *emptypb.Empty) (*emptyp
 func (UnimplementedPeerServer) Insert(context.Context, *InsertRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Insert not implemented")
 }
+func (UnimplementedPeerServer) CompareAndSwap(context.Context, *CompareAndSwapRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompareAndSwap not implemented")
+}
+func (UnimplementedPeerServer) Replicate(context.Context, *InsertRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Replicate not implemented")
+}
 func (UnimplementedPeerServer) Query(context.Context, *QueryRequest) (*QueryReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
 }
+func (UnimplementedPeerServer) QueryReplica(context.Context, *QueryRequest) (*QueryReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryReplica not implemented")
+}
+func (UnimplementedPeerServer) Search(context.Context, *SearchRequest) (*SearchReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedPeerServer) Cardinality(context.Context, *CardinalityRequest) (*CardinalityReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cardinality not implemented")
+}
+func (UnimplementedPeerServer) Delete(context.Context, *DeleteRequest) (*DeleteReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedPeerServer) DeleteReplica(context.Context, *DeleteRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteReplica not implemented")
+}
+func (UnimplementedPeerServer) Rank(context.Context, *RankRequest) (*RankReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rank not implemented")
+}
+func (UnimplementedPeerServer) DropReplicas(context.Context, *DropReplicasRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DropReplicas not implemented")
+}
 func (UnimplementedPeerServer) mustEmbedUnimplementedPeerServer() {}
 
 // UnsafePeerServer may be embedded to opt out of forward compatibility for this service.
@@ -183,6 +416,24 @@ func RegisterPeerServer(s grpc.ServiceRegistrar, srv PeerServer) {
 	s.RegisterService(&Peer_ServiceDesc, srv)
 }
 
+func _Peer_Handshake_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HandshakeMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).Handshake(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/Handshake",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).Handshake(ctx, req.(*HandshakeMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Peer_FindSuccessor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(FindSuccessorRequest)
 	if err := dec(in); err != nil {
@@ -201,6 +452,24 @@ func _Peer_FindSuccessor_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Peer_NextHop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindSuccessorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).NextHop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/NextHop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).NextHop(ctx, req.(*FindSuccessorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Peer_SetSuccessor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SetSuccessorRequest)
 	if err := dec(in); err != nil {
@@ -255,6 +524,63 @@ func _Peer_Notify_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Peer_AcceptHandoff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcceptHandoffRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).AcceptHandoff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/AcceptHandoff",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).AcceptHandoff(ctx, req.(*AcceptHandoffRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Peer_Transfer_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TransferRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PeerServer).Transfer(m, &peerTransferServer{stream})
+}
+
+type Peer_TransferServer interface {
+	Send(*TransferChunk) error
+	grpc.ServerStream
+}
+
+type peerTransferServer struct {
+	grpc.ServerStream
+}
+
+func (x *peerTransferServer) Send(m *TransferChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Peer_ConfirmHandoff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmHandoffRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).ConfirmHandoff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/ConfirmHandoff",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).ConfirmHandoff(ctx, req.(*ConfirmHandoffRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Peer_GetPredecessor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(emptypb.Empty)
 	if err := dec(in); err != nil {
@@ -273,6 +599,24 @@ func _Peer_GetPredecessor_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Peer_GetSuccessorList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).GetSuccessorList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/GetSuccessorList",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).GetSuccessorList(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Peer_Leave_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(emptypb.Empty)
 	if err := dec(in); err != nil {
@@ -327,6 +671,42 @@ func _Peer_Insert_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Peer_CompareAndSwap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareAndSwapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).CompareAndSwap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/CompareAndSwap",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).CompareAndSwap(ctx, req.(*CompareAndSwapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Peer_Replicate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).Replicate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/Replicate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).Replicate(ctx, req.(*InsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Peer_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(QueryRequest)
 	if err := dec(in); err != nil {
@@ -345,6 +725,132 @@ func _Peer_Query_Handler(srv interface{}, ctx context.Context, dec func(interfac
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Peer_QueryReplica_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).QueryReplica(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/QueryReplica",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).QueryReplica(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Peer_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Peer_Cardinality_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CardinalityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).Cardinality(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/Cardinality",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).Cardinality(ctx, req.(*CardinalityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Peer_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Peer_DeleteReplica_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).DeleteReplica(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/DeleteReplica",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).DeleteReplica(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Peer_Rank_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RankRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).Rank(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/Rank",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).Rank(ctx, req.(*RankRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Peer_DropReplicas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DropReplicasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PeerServer).DropReplicas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Peer/DropReplicas",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PeerServer).DropReplicas(ctx, req.(*DropReplicasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Peer_ServiceDesc is the grpc.ServiceDesc for Peer service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -352,10 +858,18 @@ var Peer_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "Peer",
 	HandlerType: (*PeerServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Handshake",
+			Handler:    _Peer_Handshake_Handler,
+		},
 		{
 			MethodName: "FindSuccessor",
 			Handler:    _Peer_FindSuccessor_Handler,
 		},
+		{
+			MethodName: "NextHop",
+			Handler:    _Peer_NextHop_Handler,
+		},
 		{
 			MethodName: "SetSuccessor",
 			Handler:    _Peer_SetSuccessor_Handler,
@@ -368,10 +882,22 @@ var Peer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Notify",
 			Handler:    _Peer_Notify_Handler,
 		},
+		{
+			MethodName: "AcceptHandoff",
+			Handler:    _Peer_AcceptHandoff_Handler,
+		},
+		{
+			MethodName: "ConfirmHandoff",
+			Handler:    _Peer_ConfirmHandoff_Handler,
+		},
 		{
 			MethodName: "GetPredecessor",
 			Handler:    _Peer_GetPredecessor_Handler,
 		},
+		{
+			MethodName: "GetSuccessorList",
+			Handler:    _Peer_GetSuccessorList_Handler,
+		},
 		{
 			MethodName: "Leave",
 			Handler:    _Peer_Leave_Handler,
@@ -384,11 +910,53 @@ var Peer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Insert",
 			Handler:    _Peer_Insert_Handler,
 		},
+		{
+			MethodName: "CompareAndSwap",
+			Handler:    _Peer_CompareAndSwap_Handler,
+		},
+		{
+			MethodName: "Replicate",
+			Handler:    _Peer_Replicate_Handler,
+		},
 		{
 			MethodName: "Query",
 			Handler:    _Peer_Query_Handler,
 		},
+		{
+			MethodName: "QueryReplica",
+			Handler:    _Peer_QueryReplica_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Peer_Search_Handler,
+		},
+		{
+			MethodName: "Cardinality",
+			Handler:    _Peer_Cardinality_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Peer_Delete_Handler,
+		},
+		{
+			MethodName: "DeleteReplica",
+			Handler:    _Peer_DeleteReplica_Handler,
+		},
+		{
+			MethodName: "Rank",
+			Handler:    _Peer_Rank_Handler,
+		},
+		{
+			MethodName: "DropReplicas",
+			Handler:    _Peer_DropReplicas_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Transfer",
+			Handler:       _Peer_Transfer_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "peer.proto",
 }
@@ -8,12 +8,39 @@ func (e *ErrorReply) Error() string {
 	return ""
 }
 
+// SetRequest is the body of /set. TTL is an optional duration such as 30s, after which the pair expires.
 type SetRequest struct {
-	Key     string `json:"key"`
-	Content string `json:"content"`
+	Key         string `json:"key"`
+	Content     string `json:"content"`
+	Consistency string `json:"consistency"`
+	TTL         string `json:"ttl"`
+}
+
+// WriteRequest is the body of the writes addressing the key in the path.
+type WriteRequest struct {
+	Content     string `json:"content"`
+	Consistency string `json:"consistency"`
+	TTL         string `json:"ttl"`
+}
+
+// SearchItem is a hit of a search. Version is a decimal string, since the versions exceed the integers
+// representable exactly by the JSON numbers of some clients.
+type SearchItem struct {
+	Key     string  `json:"key"`
+	Value   string  `json:"value"`
+	Version string  `json:"version"`
+	Score   float64 `json:"score"`
+	Fuzzy   bool    `json:"fuzzy,omitempty"`
+}
+
+type SearchReply struct {
+	Items  []SearchItem `json:"items"`
+	Cursor string       `json:"cursor,omitempty"`
 }
 
 type GetReply struct {
-	Size int64  `json:"size"`
-	Hash string `json:"hash"`
+	Size    int64  `json:"size"`
+	Hash    string `json:"hash"`
+	Key     string `json:"key"`
+	Version string `json:"version"`
 }
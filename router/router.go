@@ -6,15 +6,25 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/yousuf64/chord-kv/errs"
+	"github.com/yousuf64/chord-kv/hlc"
 	"github.com/yousuf64/chord-kv/kv"
+	"github.com/yousuf64/chord-kv/node"
 	"github.com/yousuf64/shift"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"google.golang.org/grpc"
 	"log"
 	"math/big"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 1000
 )
 
 type Router struct {
@@ -35,28 +45,214 @@ func New(grpcs *grpc.Server, kvs kv.KV) *Router {
 				})
 			}
 
-			err = kvs.Insert(r.Context(), req.Key, req.Content)
+			consistency, err := node.ParseConsistency(req.Consistency)
+			if err != nil {
+				return errors.Join(err, &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			ttl, err := parseTTL(req.TTL)
+			if err != nil {
+				return errors.Join(err, &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			version, err := kvs.Insert(r.Context(), req.Key, req.Content, ttl, consistency)
 			if err != nil {
 				if errors.Is(err, errs.AlreadyExistsError) {
 					return &ErrorReply{
 						Status: http.StatusBadRequest,
 					}
 				}
+				if errors.Is(err, errs.EmptyKeyError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusBadRequest,
+					})
+				}
+				if errors.Is(err, errs.InsufficientReplicasError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusServiceUnavailable,
+					})
+				}
 
 				return err
 			}
 
+			w.Header().Set("ETag", etag(version))
 			w.WriteHeader(http.StatusCreated)
 			return nil
 		})
 
+		g.PUT("/key/:key", func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+			req, consistency, err := decodeWriteRequest(r)
+			if err != nil {
+				return errors.Join(err, &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			ttl, err := parseTTL(req.TTL)
+			if err != nil {
+				return errors.Join(err, &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			// If-None-Match: * only creates the key, as /set does, while If-Match only replaces the value it matches
+			match, noneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+			if noneMatch != "" && (noneMatch != "*" || match != "") {
+				return errors.Join(errors.New("only If-None-Match: * is supported, without If-Match"), &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			create := noneMatch == "*"
+			var version hlc.Timestamp
+			if create {
+				version, err = kvs.Insert(r.Context(), route.Params.Get("key"), req.Content, ttl, consistency)
+			} else if match != "" {
+				version, err = updateIfMatch(r, kvs, route.Params.Get("key"), match, req.Content, ttl, consistency)
+			} else {
+				version, err = kvs.Put(r.Context(), route.Params.Get("key"), req.Content, ttl, consistency)
+			}
+			if err != nil {
+				if errors.Is(err, errs.AlreadyExistsError) || errors.Is(err, errs.NotFoundError) || errors.Is(err, errs.VersionMismatchError) {
+					return &ErrorReply{
+						Status: http.StatusPreconditionFailed,
+					}
+				} else if errors.Is(err, errs.EmptyKeyError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusBadRequest,
+					})
+				} else if errors.Is(err, errs.InsufficientReplicasError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusServiceUnavailable,
+					})
+				} else {
+					return err
+				}
+			}
+
+			w.Header().Set("ETag", etag(version))
+			if create {
+				w.WriteHeader(http.StatusCreated)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return nil
+		})
+
+		g.PATCH("/key/:key", func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+			req, consistency, err := decodeWriteRequest(r)
+			if err != nil {
+				return errors.Join(err, &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			ttl, err := parseTTL(req.TTL)
+			if err != nil {
+				return errors.Join(err, &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			var version hlc.Timestamp
+			match := r.Header.Get("If-Match")
+			if match != "" {
+				version, err = updateIfMatch(r, kvs, route.Params.Get("key"), match, req.Content, ttl, consistency)
+			} else {
+				version, err = kvs.Update(r.Context(), route.Params.Get("key"), req.Content, ttl, consistency)
+			}
+			if err != nil {
+				if errors.Is(err, errs.NotFoundError) && match == "" {
+					return &ErrorReply{
+						Status: http.StatusNotFound,
+					}
+				} else if errors.Is(err, errs.NotFoundError) || errors.Is(err, errs.VersionMismatchError) {
+					return &ErrorReply{
+						Status: http.StatusPreconditionFailed,
+					}
+				} else if errors.Is(err, errs.EmptyKeyError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusBadRequest,
+					})
+				} else if errors.Is(err, errs.InsufficientReplicasError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusServiceUnavailable,
+					})
+				} else {
+					return err
+				}
+			}
+
+			w.Header().Set("ETag", etag(version))
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		})
+
+		g.DELETE("/key/:key", func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+			consistency, err := node.ParseConsistency(r.URL.Query().Get("consistency"))
+			if err != nil {
+				return errors.Join(err, &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			err = kvs.Delete(r.Context(), route.Params.Get("key"), consistency)
+			if err != nil {
+				if errors.Is(err, errs.NotFoundError) {
+					return &ErrorReply{
+						Status: http.StatusNotFound,
+					}
+				} else if errors.Is(err, errs.EmptyKeyError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusBadRequest,
+					})
+				} else if errors.Is(err, errs.InsufficientReplicasError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusServiceUnavailable,
+					})
+				} else {
+					return err
+				}
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		})
+
 		g.GET("/get/:key", func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
-			_, err := kvs.Get(r.Context(), route.Params.Get("key"))
+			consistency, err := node.ParseConsistency(r.URL.Query().Get("consistency"))
+			if err != nil {
+				return errors.Join(err, &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			fuzzy, err := parseFuzzy(r)
+			if err != nil {
+				return errors.Join(err, &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			hit, err := kvs.Get(r.Context(), route.Params.Get("key"), consistency, fuzzy)
 			if err != nil {
 				if errors.Is(err, errs.NotFoundError) {
 					return &ErrorReply{
 						Status: http.StatusNotFound,
 					}
+				} else if errors.Is(err, errs.InsufficientReplicasError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusServiceUnavailable,
+					})
+				} else if errors.Is(err, errs.InvalidQueryError) || errors.Is(err, errs.FuzzyDisabledError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusBadRequest,
+					})
 				} else {
 					return err
 				}
@@ -64,13 +260,59 @@ func New(grpcs *grpc.Server, kvs kv.KV) *Router {
 
 			size, hash := generateContent()
 
-			err = json.NewEncoder(w).Encode(&GetReply{Size: size, Hash: hash})
+			w.Header().Set("ETag", etag(hit.Version))
+			err = json.NewEncoder(w).Encode(&GetReply{Size: size, Hash: hash, Key: hit.Key, Version: hit.Version.String()})
 			if err != nil {
 				return err
 			}
 			return nil
 		})
 
+		g.GET("/search", func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+			query := r.URL.Query().Get("q")
+			if query == "" {
+				return errors.Join(errors.New("missing query"), &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			limit := defaultSearchLimit
+			if l := r.URL.Query().Get("limit"); l != "" {
+				var err error
+				limit, err = strconv.Atoi(l)
+				if err != nil || limit < 1 || limit > maxSearchLimit {
+					return errors.Join(fmt.Errorf("limit must be within 1 and %d", maxSearchLimit), &ErrorReply{
+						Status: http.StatusBadRequest,
+					})
+				}
+			}
+
+			fuzzy, err := parseFuzzy(r)
+			if err != nil {
+				return errors.Join(err, &ErrorReply{
+					Status: http.StatusBadRequest,
+				})
+			}
+
+			result, err := kvs.Search(r.Context(), query, r.URL.Query().Get("cursor"), limit, fuzzy)
+			if err != nil {
+				if errors.Is(err, errs.InvalidCursorError) || errors.Is(err, errs.InvalidQueryError) || errors.Is(err, errs.FuzzyDisabledError) {
+					return errors.Join(err, &ErrorReply{
+						Status: http.StatusBadRequest,
+					})
+				}
+
+				return err
+			}
+
+			reply := SearchReply{Items: make([]SearchItem, 0, len(result.Hits)), Cursor: result.Cursor}
+			for _, hit := range result.Hits {
+				reply.Items = append(reply.Items, SearchItem{Key: hit.Key, Value: hit.Value, Version: hit.Version.String(), Score: hit.Score, Fuzzy: hit.Fuzzy})
+			}
+
+			return json.NewEncoder(w).Encode(&reply)
+		})
+
 		g.GET("/debug", func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
 			_, err := w.Write([]byte(kvs.Debug()))
 			if err != nil {
@@ -79,6 +321,15 @@ func New(grpcs *grpc.Server, kvs kv.KV) *Router {
 
 			return nil
 		})
+
+		g.GET("/debug/lookup/:key", func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+			_, err := w.Write([]byte(kvs.DebugLookup(r.Context(), route.Params.Get("key"))))
+			if err != nil {
+				return err
+			}
+
+			return nil
+		})
 	})
 
 	return &Router{
@@ -114,6 +365,77 @@ func generateContent() (int64, string) {
 	return size.Int64(), hashString
 }
 
+// decodeWriteRequest decodes the body of a write along with its consistency level.
+func decodeWriteRequest(r *http.Request) (WriteRequest, node.Consistency, error) {
+	req := WriteRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return req, node.One, err
+	}
+
+	consistency, err := node.ParseConsistency(req.Consistency)
+	return req, consistency, err
+}
+
+// etag formats the version of a KV pair as an entity tag.
+func etag(version hlc.Timestamp) string {
+	return strconv.Quote(version.String())
+}
+
+// parseETag parses an entity tag formatted by etag. The weak tags are rejected, since If-Match compares strongly.
+func parseETag(tag string) (hlc.Timestamp, error) {
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil || !strings.HasPrefix(tag, `"`) {
+		return 0, fmt.Errorf("malformed entity tag %s", tag)
+	}
+
+	return hlc.Parse(unquoted)
+}
+
+// updateIfMatch replaces the value of the key when it matches the If-Match header, either * matching any value
+// or the entity tag of the version to compare and swap.
+func updateIfMatch(r *http.Request, kvs kv.KV, key string, match string, value string, ttl time.Duration, consistency node.Consistency) (hlc.Timestamp, error) {
+	if match == "*" {
+		return kvs.Update(r.Context(), key, value, ttl, consistency)
+	}
+
+	expected, err := parseETag(match)
+	if err != nil {
+		return 0, errors.Join(err, &ErrorReply{
+			Status: http.StatusBadRequest,
+		})
+	}
+
+	return kvs.CompareAndSwap(r.Context(), key, expected, value, ttl, consistency)
+}
+
+// parseTTL parses the optional time-to-live of a write, zero when the pair never expires.
+func parseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("ttl must be positive, got %s", s)
+	}
+
+	return ttl, nil
+}
+
+// parseFuzzy parses the optional fuzzy query parameter, off by default.
+func parseFuzzy(r *http.Request) (bool, error) {
+	fuzzy := r.URL.Query().Get("fuzzy")
+	if fuzzy == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(fuzzy)
+}
+
 func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
 		router.GrpcHandler.ServeHTTP(w, r)
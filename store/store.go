@@ -0,0 +1,104 @@
+package store
+
+import (
+	"encoding/json"
+	"github.com/yousuf64/chord-kv/hlc"
+	"github.com/yousuf64/chord-kv/node"
+	"github.com/yousuf64/chord-kv/query"
+	"github.com/yousuf64/chord-kv/util"
+	"time"
+)
+
+// Store holds the items of a node, grouped into buckets by the ID of their index.
+type Store interface {
+	// Add stores the item in the bucket. Fails with errs.AlreadyExistsError when the bucket holds
+	// an item of the same index and key.
+	//
+	// The writes resolve by last-writer-wins on the versions of the items: the writes older than the tombstone
+	// of the item are ignored, and so are the Puts and the Replaces older than the item held.
+	Add(bucketId util.ID, item node.InsertItem) error
+	// Put stores the item in the bucket or replaces the item of the same index and key, reporting whether the item existed.
+	Put(bucketId util.ID, item node.InsertItem) (bool, error)
+	// Replace replaces the item of the same index and key in the bucket, reporting whether it existed.
+	// Leaves the bucket untouched when the item is missing.
+	Replace(bucketId util.ID, item node.InsertItem) (bool, error)
+	// Swap atomically replaces the item of the same index and key in the bucket when it is of the expected version,
+	// failing with errs.VersionMismatchError otherwise. Reports whether the item existed, leaving the bucket untouched
+	// when missing. The version of the item replacing it is expected to be later than the expected one.
+	Swap(bucketId util.ID, item node.InsertItem, expected hlc.Timestamp) (bool, error)
+	// Remove deletes the item with the index and the key from the bucket when it is still of the version,
+	// reporting whether it got removed. The writes landing in between keep the item around.
+	Remove(bucketId util.ID, index string, key string, version hlc.Timestamp) (bool, error)
+	// Delete removes the item with the index and the key from the bucket unless it is of a later version,
+	// leaving a tombstone of the version in its place, so that the stale copies of the item arriving later on
+	// are told apart from the new writes. Reports whether the item got removed.
+	Delete(bucketId util.ID, index string, key string, version hlc.Timestamp) (bool, error)
+	// Get returns the item with the index and the key, if the bucket holds it.
+	Get(bucketId util.ID, index string, key string) (node.InsertItem, bool)
+	// Tombstone returns the version of the tombstone of the item with the index and the key, if the bucket holds one.
+	Tombstone(bucketId util.ID, index string, key string) (hlc.Timestamp, bool)
+	// Forget drops the tombstone of the item with the index and the key from the bucket when it is still of the version,
+	// reporting whether it got dropped.
+	Forget(bucketId util.ID, index string, key string, version hlc.Timestamp) (bool, error)
+	// Query returns the first item of the index in the bucket whose key contains the words of the query in order.
+	// Query and Search leave out the expired items, and the writes treat them as missing.
+	Query(bucketId util.ID, index string, query string) (node.InsertItem, bool)
+	// Search returns up to limit items matching the query as Query does, ordered by key, starting after the key after.
+	Search(bucketId util.ID, index string, query string, after string, limit int) []node.InsertItem
+	// Rank scores the items of the index in the bucket matching the query as Search does, by passing the tokens of
+	// their keys to score, which reports whether it matches them. Returns up to limit of the matches ranking after the
+	// boundary, in rank order, keeping only the top ones as it goes.
+	Rank(bucketId util.ID, index string, query string, score Scorer, after query.Rank, limit int) []node.RankedItem
+	// Scan returns up to limit items and tombstones of the buckets within the range (lo, hi] without removing them,
+	// resuming after the cursor, along with the cursor to resume from and whether the range got exhausted.
+	// The tombstones come as the deletions of the items they stand for. A limit of zero returns the rest of the range.
+	Scan(lo util.ID, hi util.ID, after Cursor, limit int) (items []node.InsertItem, deletes []node.DeleteItem, next Cursor, done bool)
+	// Count returns the number of the items and the tombstones of the buckets within the range (lo, hi].
+	Count(lo util.ID, hi util.ID) int
+	// GetAndDeleteRange removes and returns the items and the tombstones of the buckets within the range (lo, hi].
+	GetAndDeleteRange(lo util.ID, hi util.ID) ([]node.InsertItem, []node.DeleteItem, error)
+	// Cardinality returns the number of items of the index in the bucket.
+	Cardinality(bucketId util.ID, index string) int
+	Stats() Stats
+	// Expire evicts the items whose deadlines passed by the time, returning the number of the items evicted.
+	Expire(now time.Time) (int, error)
+	// Compact reclaims the space of the deleted items, if the store keeps track of them,
+	// and drops the tombstones whose versions are older than the TTL of the store.
+	Compact() error
+	Close() error
+
+	// DEBUG
+	Debug() json.RawMessage
+}
+
+// Scorer scores a key given its tokens, reporting whether the key matches at all and whether only within the edit distance.
+type Scorer func(tokens []string) (score float64, fuzzy bool, ok bool)
+
+// Cursor is the position a Scan resumes from. The buckets are scanned in the order of their IDs,
+// the items of each bucket in the order they were inserted, followed by its tombstones in the order of their keys.
+// The zero value starts from the beginning of the range.
+type Cursor struct {
+	// Bucket is the bucket scanned last, when Started.
+	Bucket  util.ID
+	Started bool
+	// Next is the position of the next item of the bucket to return.
+	Next uint64
+	// Tombstones tells that the items of the bucket are all returned, and the tombstones up to Index and Key as well.
+	Tombstones bool
+	Index      string
+	Key        string
+}
+
+type Stats struct {
+	Buckets int `json:"buckets"`
+	Items   int `json:"items"`
+	// Keys estimates the number of distinct keys of the items. Every key is stored under each of its words,
+	// so each item adds up one over the number of words in its key. Items and Keys leave out the fuzzy variants.
+	Keys float64 `json:"keys"`
+}
+
+// Engine opens the stores of the nodes. Each node opens a store for its own items
+// and another one for the replicas it holds, told apart by the name.
+type Engine interface {
+	Open(space util.Space, id util.ID, name string) (Store, error)
+}
@@ -1,23 +1,139 @@
 package util
 
 import (
+	"bytes"
 	"crypto/sha1"
-	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
 )
 
-var M = 3
-var RingSize uint = 9
+// HashFunction is the name of the hash function mapping the keys to the identifier space.
+const HashFunction = "sha1"
 
-func Hash(key string) uint64 {
-	h := sha1.New()
-	h.Write([]byte(key))
-	b := h.Sum(nil)
-	return binary.BigEndian.Uint64(b) % uint64(RingSize)
+// MaxM is the number of bits in a SHA-1 digest, which bounds the size of the identifier space.
+const MaxM = sha1.Size * 8
+
+// ID is an identifier in the ring, stored as a big-endian unsigned integer of up to MaxM bits.
+// Being an array, IDs can be compared with == and used as map keys.
+type ID [sha1.Size]byte
+
+func NewID(v uint64) ID {
+	return IDFromBig(new(big.Int).SetUint64(v))
+}
+
+// IDFromBytes interprets b as a big-endian unsigned integer.
+func IDFromBytes(b []byte) ID {
+	return IDFromBig(new(big.Int).SetBytes(b))
+}
+
+func IDFromBig(v *big.Int) ID {
+	var id ID
+	v.FillBytes(id[:])
+	return id
+}
+
+// ParseID parses the decimal representation of an ID.
+func ParseID(s string) (ID, error) {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok || v.Sign() < 0 || v.BitLen() > MaxM {
+		return ID{}, fmt.Errorf("invalid id %q", s)
+	}
+
+	return IDFromBig(v), nil
+}
+
+func (id ID) Big() *big.Int {
+	return new(big.Int).SetBytes(id[:])
+}
+
+func (id ID) Bytes() []byte {
+	return id[:]
+}
+
+func (id ID) Cmp(other ID) int {
+	return bytes.Compare(id[:], other[:])
+}
+
+func (id ID) String() string {
+	return id.Big().String()
+}
+
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// MarshalJSON renders the ID as a JSON number.
+func (id ID) MarshalJSON() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// Space is the identifier space of a ring.
+// Nodes and keys of the same ring have to agree on the identifier space.
+type Space struct {
+	// M is the number of bits in the identifiers, which is also the number of fingers in the finger table.
+	M int
+	// RingSize is the number of identifiers in the ring.
+	RingSize *big.Int
+}
+
+// NewSpace creates an identifier space of m bits. A ringSize of 0 spans the whole 2^m identifiers.
+func NewSpace(m int, ringSize uint64) Space {
+	if m < 1 || m > MaxM {
+		panic(fmt.Sprintf("M must be within 1 and %d", MaxM))
+	}
+
+	size := new(big.Int).SetUint64(ringSize)
+	if ringSize == 0 {
+		size = new(big.Int).Lsh(big.NewInt(1), uint(m))
+	}
+
+	return Space{M: m, RingSize: size}
+}
+
+func (s Space) Hash(key string) ID {
+	h := sha1.Sum([]byte(key))
+	return IDFromBig(new(big.Int).Mod(new(big.Int).SetBytes(h[:]), s.RingSize))
+}
+
+func (s Space) Between(id, start, end ID) bool {
+	if start.Cmp(end) < 0 {
+		return id.Cmp(start) > 0 && id.Cmp(end) <= 0 // 3 ...5 8 9... 12
+	}
+	return id.Cmp(start) > 0 || id.Cmp(end) <= 0
+}
+
+// Share returns the fraction of the ring within (start, end]. Equal bounds span the whole ring.
+func (s Space) Share(start, end ID) float64 {
+	if start == end {
+		return 1
+	}
+
+	v := new(big.Int).Sub(end.Big(), start.Big())
+	v.Mod(v, s.RingSize)
+	share, _ := new(big.Rat).SetFrac(v, s.RingSize).Float64()
+	return share
+}
+
+// Finger returns the start of the finger-th finger of the node, (id + 2^(finger-1)) mod RingSize.
+func (s Space) Finger(id ID, finger int) ID {
+	v := new(big.Int).Lsh(big.NewInt(1), uint(finger-1))
+	v.Add(v, id.Big())
+	return IDFromBig(v.Mod(v, s.RingSize))
+}
+
+// UnixMilli returns the time in milliseconds since the Unix epoch, 0 for the zero time.
+func UnixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
 }
 
-func Between(id, start, end uint64) bool {
-	if start < end {
-		return id > start && id <= end // 3 ...5 8 9... 12
+// FromUnixMilli returns the time of the milliseconds since the Unix epoch, the zero time for 0.
+func FromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
 	}
-	return id > start || id <= end
+	return time.UnixMilli(ms)
 }